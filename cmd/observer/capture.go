@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/pixie"
+	"orbservability/observer/pkg/processor"
+	"orbservability/observer/pkg/sink"
+)
+
+// record executes the scripts against the sources like the daemon, writing
+// the tables they produce to a capture file instead of handling them, until
+// interrupted or the duration has passed.
+func record(args []string) error {
+	fs := newFlagSet("record")
+	path := fs.String("capture", "", "path of the capture file to create")
+	duration := fs.Duration("duration", 0, "stop recording after this long, 0 records until interrupted")
+	flags := config.RegisterFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return usageErrorf(fs, "-capture is required")
+	}
+
+	cfg, err := flags.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if *duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	sources, err := pixie.ConnectSources(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("creating Pixie client: %w", err)
+	}
+	recorder, err := pixie.NewRecorder(*path)
+	if err != nil {
+		return fmt.Errorf("creating capture file: %w", err)
+	}
+
+	log.Info().Str("capture", *path).Msg("Recording")
+	err = pixie.NewRecordingSupervisor(recorder).Run(ctx, sources, cfg)
+	if closeErr := recorder.Close(); closeErr != nil {
+		return fmt.Errorf("writing capture file: %w", closeErr)
+	}
+	if err != nil {
+		return fmt.Errorf("recording records: %w", err)
+	}
+	log.Info().Str("capture", *path).Msg("Recorded")
+	return nil
+}
+
+// replay feeds a capture file through the processors and the sinks of the
+// configuration, as if its tables were streamed from the sources again.
+func replay(args []string) error {
+	fs := newFlagSet("replay")
+	path := fs.String("capture", "", "path of the capture file to replay")
+	speed := fs.Float64("speed", 1, "replay this many times faster than recorded, 0 replays as fast as possible")
+	flags := config.RegisterFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return usageErrorf(fs, "-capture is required")
+	}
+	if *speed < 0 {
+		return usageErrorf(fs, "-speed must not be negative, got %g", *speed)
+	}
+
+	cfg, err := flags.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	processors, err := processor.New(cfg.Processors)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	eventGateway, grpcConn, err := dialGateway(cfg)
+	if err != nil {
+		return err
+	}
+	if grpcConn != nil {
+		defer grpcConn.Close()
+	}
+	sinks, err := sink.Open(cfg, eventGateway)
+	if err != nil {
+		return fmt.Errorf("opening sinks: %w", err)
+	}
+	sinkFailure := cancelOnFailure(ctx, cancel, sinks)
+
+	err = pixie.Replay(ctx, *path, *speed, sinks, processors)
+	sinks.Close(context.Background())
+	if failure := sinkFailure(); failure != nil {
+		return fmt.Errorf("sending events: %w", failure)
+	}
+	if err != nil {
+		return fmt.Errorf("replaying capture: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/pixie"
+	"orbservability/observer/pkg/processor"
+	"orbservability/observer/pkg/sink"
+)
+
+// validateConfig loads the configuration like the daemon and prints every
+// problem found, without connecting to anything.
+func validateConfig(args []string) error {
+	fs := newFlagSet("validate-config")
+	flags := config.RegisterFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadChecked(flags)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Configuration is valid: %d source(s), %d script(s), %d processor(s), %d sink(s)\n",
+		len(cfg.Sources), len(cfg.Scripts), len(cfg.Processors), len(cfg.Sinks))
+	return nil
+}
+
+// loadChecked loads the configuration and its processors, printing the
+// problems of an invalid configuration one per line.
+func loadChecked(flags *config.Flags) (*config.Config, error) {
+	cfg, err := flags.Load()
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		fmt.Printf("Configuration is invalid, %d problem(s):\n", len(invalid.Problems))
+		for _, p := range invalid.Problems {
+			fmt.Printf("  - %s\n", p)
+		}
+		return nil, errors.New("invalid configuration")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	if _, err := processor.New(cfg.Processors); err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	for _, w := range cfg.Warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
+	return cfg, nil
+}
+
+// checkScript executes the configured scripts once against every source and
+// prints the tables they return, so that a script can be tried before it is
+// deployed.
+func checkScript(args []string) error {
+	fs := newFlagSet("check-script")
+	scriptName := fs.String("script", "", "only execute the configured script with this name")
+	sourceName := fs.String("source", "", "only execute against the configured source with this name")
+	timeout := fs.Duration("timeout", 30*time.Second, "time allowed for each execution, a streaming script is stopped after it")
+	flags := config.RegisterFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadChecked(flags)
+	if err != nil {
+		return err
+	}
+	scripts := cfg.Scripts
+	if *scriptName != "" {
+		scripts = nil
+		for _, script := range cfg.Scripts {
+			if script.Name == *scriptName {
+				scripts = append(scripts, script)
+			}
+		}
+		if len(scripts) == 0 {
+			return usageErrorf(fs, "no script named %q in the configuration", *scriptName)
+		}
+	}
+	sources := cfg.Sources
+	if *sourceName != "" {
+		sources = nil
+		for _, source := range cfg.Sources {
+			if source.Name == *sourceName {
+				sources = append(sources, source)
+			}
+		}
+		if len(sources) == 0 {
+			return usageErrorf(fs, "no source named %q in the configuration", *sourceName)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	failed := 0
+	for _, sc := range sources {
+		single := *cfg
+		single.Sources = []config.PixieSource{sc}
+		connected, err := pixie.ConnectSources(ctx, &single)
+		if err != nil {
+			fmt.Printf("%s: FAIL %v\n", sc.Name, err)
+			failed += len(scripts)
+			continue
+		}
+		source := connected[0]
+
+		for _, script := range scripts {
+			execCtx, cancelExec := context.WithTimeout(ctx, *timeout)
+			started := time.Now()
+			tables, err := pixie.CheckScript(execCtx, source, script)
+			streaming := execCtx.Err() != nil
+			cancelExec()
+			if ctx.Err() != nil {
+				source.Close()
+				return ctx.Err()
+			}
+
+			switch {
+			case err != nil:
+				failed++
+				fmt.Printf("%s / %s: FAIL %v\n", sc.Name, script.Name, err)
+			case streaming:
+				fmt.Printf("%s / %s: ok, %d table(s), still streaming after %s\n", sc.Name, script.Name, len(tables), *timeout)
+			default:
+				fmt.Printf("%s / %s: ok, %d table(s) in %s\n", sc.Name, script.Name, len(tables), time.Since(started).Round(time.Millisecond))
+			}
+			for _, table := range tables {
+				columns := make([]string, len(table.Metadata.ColInfo))
+				for i, col := range table.Metadata.ColInfo {
+					columns[i] = fmt.Sprintf("%s %s", col.Name, col.Type)
+				}
+				fmt.Printf("  %s: %d record(s); %s\n", table.Metadata.Name, table.Records, strings.Join(columns, ", "))
+			}
+		}
+		source.Close()
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d script execution(s) failed", failed, len(sources)*len(scripts))
+	}
+	return nil
+}
+
+// doctor checks, one after the other, everything the daemon needs at
+// startup and prints the outcome of each check.
+func doctor(args []string) error {
+	fs := newFlagSet("doctor")
+	timeout := fs.Duration("timeout", 10*time.Second, "time allowed for each check")
+	flags := config.RegisterFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	d := &diagnosis{ctx: ctx, timeout: *timeout}
+
+	cfg, err := loadChecked(flags)
+	if err != nil {
+		d.report("configuration", err)
+		return d.err()
+	}
+	d.report("configuration", nil)
+
+	for _, sc := range cfg.Sources {
+		d.check("source "+sc.Name+" at "+sc.URL, func(ctx context.Context) error {
+			return pixie.Ping(ctx, sc)
+		})
+	}
+
+	eventGateway, grpcConn, err := dialGateway(cfg)
+	switch {
+	case err != nil:
+		d.report("gateway at "+cfg.Gateway.URL, err)
+	case grpcConn != nil:
+		defer grpcConn.Close()
+		d.check("gateway at "+cfg.Gateway.URL, func(ctx context.Context) error {
+			return waitReady(ctx, grpcConn)
+		})
+	}
+
+	for _, sc := range cfg.Sinks {
+		name := "sink " + sc.Name
+		switch {
+		case sc.Type == config.SinkServer:
+			d.skip(name, "would take over the address of a running observer")
+		case sc.Type == config.SinkGateway && eventGateway == nil:
+			d.skip(name, "no connection to the gateway")
+		default:
+			d.check(name, func(ctx context.Context) error {
+				return sink.Check(ctx, cfg, sc, eventGateway)
+			})
+		}
+	}
+	return d.err()
+}
+
+// waitReady connects conn, waiting until it is ready or ctx is done.
+func waitReady(ctx context.Context, conn *grpc.ClientConn) error {
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("connection %s: %w", strings.ToLower(state.String()), ctx.Err())
+		}
+	}
+}
+
+// diagnosis prints the outcome of the checks made by doctor.
+type diagnosis struct {
+	ctx     context.Context
+	timeout time.Duration
+	checks  int
+	failed  int
+}
+
+// check runs fn with the time allowed for a check and reports its outcome.
+func (d *diagnosis) check(name string, fn func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.timeout)
+	defer cancel()
+	d.report(name, fn(ctx))
+}
+
+func (d *diagnosis) report(name string, err error) {
+	d.checks++
+	if err != nil {
+		d.failed++
+		fmt.Printf("FAIL  %s: %v\n", name, err)
+		return
+	}
+	fmt.Printf("ok    %s\n", name)
+}
+
+func (d *diagnosis) skip(name string, reason string) {
+	fmt.Printf("skip  %s: %s\n", name, reason)
+}
+
+func (d *diagnosis) err() error {
+	if d.failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", d.failed, d.checks)
+	}
+	return nil
+}
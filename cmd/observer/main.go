@@ -1,50 +1,210 @@
 package main
 
 import (
-	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/orbservability/io/pkg/client"
 	_ "github.com/orbservability/telemetry/pkg/logs"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 
 	"orbservability/observer/pkg/config"
 	"orbservability/observer/pkg/eventgateway"
-	"orbservability/observer/pkg/pixie"
 )
 
+// Exit codes of every command.
+const (
+	exitOK      = 0
+	exitFailure = 1 // The command ran and failed, e.g. an invalid configuration or an unreachable source
+	exitUsage   = 2 // The command line is invalid
+)
+
+// command is a subcommand of the observer binary.
+type command struct {
+	name    string
+	args    string // Synopsis of the arguments, after the name
+	summary string
+	run     func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "run", args: "[flags]", summary: "Stream events from the Pixie sources to the sinks (default)", run: run},
+		{name: "validate-config", args: "[flags]", summary: "Check the configuration and print where each problem comes from", run: validateConfig},
+		{name: "check-script", args: "[-script name] [-source name] [-timeout d] [flags]", summary: "Execute the PxL scripts once and print the tables they return", run: checkScript},
+		{name: "tail", args: "[-addr addr] [-namespaces ns,...] [-services svc,...] [-protocols proto,...] [flags]", summary: "Print the events served by a running observer's subscriptions sink", run: tail},
+		{name: "doctor", args: "[-timeout d] [flags]", summary: "Check that the sources, the event gateway and the sinks can be reached", run: doctor},
+		{name: "record", args: "-capture file [-duration d] [flags]", summary: "Write the tables returned by the PxL scripts to a capture file", run: record},
+		{name: "replay", args: "-capture file [-speed n] [flags]", summary: "Feed a capture file through the processors and the sinks", run: replay},
+		{name: "version", args: "", summary: "Print the version of the observer", run: version},
+		{name: "help", args: "[command]", summary: "Print the help of a command", run: help},
+	}
+}
+
 func main() {
-	ctx := context.Background()
+	os.Exit(execute(os.Args[1:]))
+}
 
-	// Load Config
-	cfg, err := config.NewConfig()
-	if err != nil {
-		log.Fatal().Err(err).Msg("Error loading config")
+// execute runs the command named by the first argument, or run when the
+// first argument is a flag, returning the process exit code.
+func execute(args []string) int {
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	} else if len(args) > 0 && isHelpFlag(args[0]) {
+		usage(os.Stdout)
+		return exitOK
 	}
 
-	// Create a Pixie client
-	pixieClient, err := pixie.CreateClient(ctx, cfg)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Error creating Pixie client")
+	cmd, ok := lookup(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "observer: unknown command %q\n\n", name)
+		usage(os.Stderr)
+		return exitUsage
 	}
 
-	// Initialize gRPC client
-	eventGateway := &eventgateway.ServiceClient{}
-	grpcConn, err := client.DialGRPC(cfg.OrbservabilityURL, eventGateway, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		log.Fatal().Err(err).Msg("Error creating gRPC connection")
+	err := cmd.run(args)
+	var usageErr usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &usageErr):
+		return exitUsage
+	default:
+		log.Error().Err(err).Str("command", cmd.name).Msg("Command failed")
+		return exitFailure
 	}
-	defer grpcConn.Close()
-	grpcStream, err := eventGateway.StreamEvents(ctx)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Error creating gRPC stream")
+}
+
+func lookup(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
 	}
-	defer grpcStream.CloseAndRecv()
+	return command{}, false
+}
+
+func isHelpFlag(arg string) bool {
+	switch arg {
+	case "-h", "-help", "--help":
+		return true
+	}
+	return false
+}
+
+// usage prints the commands of the binary to w.
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: observer [command] [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun 'observer help <command>' or 'observer <command> -h' for the flags of a command.\n")
+	fmt.Fprintf(w, "Exit codes: %d on success, %d when the command fails, %d when the command line is invalid.\n", exitOK, exitFailure, exitUsage)
+}
 
-	// Execute PxL scripts and handle records
-	tm := &pixie.TableMux{GrpcStream: grpcStream}
-	if err := pixie.ExecuteAndStream(ctx, pixieClient, cfg, tm); err != nil {
-		log.Fatal().Err(err).Msg("Error handling records")
+// help prints the usage of the binary, or the flags of the named command.
+func help(args []string) error {
+	fs := newFlagSet("help")
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return err
+	} else if err != nil {
+		return usageError{err}
+	}
+	if fs.NArg() == 0 {
+		usage(os.Stdout)
+		return nil
+	}
+	cmd, ok := lookup(fs.Arg(0))
+	if !ok || fs.NArg() > 1 {
+		return usageErrorf(fs, "unknown command %q", strings.Join(fs.Args(), " "))
+	}
+	return cmd.run([]string{"-h"})
+}
+
+// usageError is returned by a command whose command line is invalid, once
+// the problem has been printed together with the command's usage.
+type usageError struct {
+	error
+}
+
+// newFlagSet returns the flag set of the named command, printing its
+// synopsis and summary above the flags on -h.
+func newFlagSet(name string) *flag.FlagSet {
+	cmd, _ := lookup(name)
+	fs := flag.NewFlagSet("observer "+name, flag.ContinueOnError)
+	synopsis := "observer " + cmd.name
+	if cmd.args != "" {
+		synopsis += " " + cmd.args
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s\n\n%s.\n", synopsis, cmd.summary)
+		if hasFlags(fs) {
+			fmt.Fprintf(fs.Output(), "\nFlags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// parseFlags parses args, which must not hold anything but flags. On an
+// invalid command line, the problem and the usage are printed to stderr and a
+// usageError is returned.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return err
+	case err != nil:
+		return usageError{err} // Printed by fs
+	case fs.NArg() > 0:
+		return usageErrorf(fs, "unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+// usageErrorf prints a problem with the command line and the usage of fs to
+// stderr, returning a usageError.
+func usageErrorf(fs *flag.FlagSet, format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	fmt.Fprintf(fs.Output(), "%s\n", err)
+	fs.Usage()
+	return usageError{err}
+}
+
+// dialGateway connects to the event gateway, if one is configured, returning
+// a nil client and connection otherwise.
+func dialGateway(cfg *config.Config) (*eventgateway.ServiceClient, *grpc.ClientConn, error) {
+	if cfg.Gateway.URL == "" {
+		return nil, nil, nil
+	}
+	creds := insecure.NewCredentials()
+	if cfg.Gateway.TLS.Enabled {
+		tlsConfig, err := cfg.Gateway.TLS.ClientConfig(cfg.Gateway.URL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("gateway TLS config: %w", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	eventGateway := &eventgateway.ServiceClient{}
+	grpcConn, err := client.DialGRPC(cfg.Gateway.URL, eventGateway, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, nil, fmt.Errorf("gateway connection: %w", err)
 	}
+	return eventGateway, grpcConn, nil
 }
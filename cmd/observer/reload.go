@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/eventgateway"
+	"orbservability/observer/pkg/metrics"
+	"orbservability/observer/pkg/pixie"
+	"orbservability/observer/pkg/processor"
+	"orbservability/observer/pkg/sink"
+)
+
+// reloader applies configuration changes, made locally and signalled with
+// SIGHUP or pushed by the event gateway, to the running streams, processors,
+// sinks, gateway connection, metrics server and remote configuration watch.
+// A configuration that is invalid or cannot be applied is rejected as a whole
+// and the configuration in effect stays in effect.
+type reloader struct {
+	ctx        context.Context // Lifetime of the metrics server and remote configuration watch
+	flags      *config.Flags   // Parsed from the command line, applied to every reload
+	supervisor *pixie.Supervisor
+	pipeline   *processor.Pipeline
+	sinks      *sink.Set
+
+	mu          sync.Mutex
+	local       *config.Config // Loaded from the file, environment and flags
+	remote      *config.Remote // Last remote configuration applied, if any
+	current     *config.Config // In effect: local with remote applied
+	gateway     *eventgateway.ServiceClient
+	conn        *grpc.ClientConn   // Of gateway, nil when no gateway is configured
+	stopMetrics context.CancelFunc // Stops the metrics server, nil when not serving
+	metricsDone chan struct{}      // Closed once the metrics server stopped
+	stopRemote  context.CancelFunc // Stops the remote configuration watch, nil when not watching
+}
+
+func newReloader(ctx context.Context, flags *config.Flags, cfg *config.Config, supervisor *pixie.Supervisor, pipeline *processor.Pipeline, sinks *sink.Set, gateway *eventgateway.ServiceClient, conn *grpc.ClientConn) *reloader {
+	return &reloader{
+		ctx:        ctx,
+		flags:      flags,
+		supervisor: supervisor,
+		pipeline:   pipeline,
+		sinks:      sinks,
+		local:      cfg,
+		current:    cfg,
+		gateway:    gateway,
+		conn:       conn,
+	}
+}
+
+// start serves the metrics and, if enabled, watches the remote configuration.
+func (r *reloader) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.serveMetrics(r.current)
+	r.watchRemote(r.current)
+}
+
+// close drains and closes the sinks, then closes the gateway connection.
+func (r *reloader) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sinks.Close(context.Background())
+	if r.conn != nil {
+		r.conn.Close()
+	}
+}
+
+// reloadOnHangup reloads the local configuration every time hangup receives
+// SIGHUP, until r.ctx is done.
+func (r *reloader) reloadOnHangup(hangup <-chan os.Signal) {
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-hangup:
+			r.reloadLocal()
+		}
+	}
+}
+
+// reloadLocal loads the local configuration again, keeping the last remote
+// configuration applied on top of it while remote configuration is enabled.
+func (r *reloader) reloadLocal() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	local, changes, err := r.flags.Reload(r.local)
+	if err != nil {
+		log.Error().Err(err).Strs("changes", changeStrings(changes)).Msg("Configuration reload rejected")
+		return
+	}
+	cfg := local
+	if r.remote != nil && local.Remote.Enabled {
+		if cfg, err = local.WithRemote(*r.remote); err != nil {
+			log.Error().Err(err).Strs("changes", changeStrings(changes)).Msg("Configuration reload rejected")
+			return
+		}
+	}
+	applied, err := r.apply(cfg)
+	if err != nil {
+		log.Error().Err(err).Strs("changes", changeStrings(applied)).Msg("Configuration reload rejected")
+		return
+	}
+	r.local = local
+	if !local.Remote.Enabled {
+		r.remote = nil
+	}
+	logWarnings(local)
+
+	if len(applied) == 0 {
+		log.Info().Msg("Configuration unchanged")
+		return
+	}
+	log.Info().Strs("changes", changeStrings(applied)).Msg("Configuration reloaded")
+}
+
+// applyRemote applies a configuration pushed by the event gateway on top of
+// the local configuration, unless ctx, the watch it was received by, is done.
+func (r *reloader) applyRemote(ctx context.Context, remote config.Remote) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err // Replaced by a watch of a new gateway or remote configuration
+	}
+
+	cfg, err := r.local.WithRemote(remote)
+	if err != nil {
+		log.Error().Err(err).Str("version", remote.Version).Msg("Remote configuration rejected")
+		return err
+	}
+	applied, err := r.apply(cfg)
+	if err != nil {
+		log.Error().Err(err).Str("version", remote.Version).Strs("changes", changeStrings(applied)).Msg("Remote configuration rejected")
+		return err
+	}
+	r.remote = &remote
+
+	log.Info().Str("version", remote.Version).Strs("changes", changeStrings(applied)).Msg("Remote configuration applied")
+	return nil
+}
+
+// apply makes cfg the configuration in effect, returning how it differs from
+// the previous one. Everything that can fail, dialing a new gateway and
+// opening new sinks, is done before the supervisor applies cfg and undone if
+// it rejects cfg, so that nothing changes unless all of cfg applies.
+// r.mu must be held.
+func (r *reloader) apply(cfg *config.Config) ([]config.Change, error) {
+	changes := config.Diff(r.current, cfg)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	processors, err := processor.New(cfg.Processors)
+	if err != nil {
+		return changes, err
+	}
+
+	redial := config.ChangedUnder(changes, "gateway")
+	gateway, conn := r.gateway, r.conn
+	if redial {
+		if gateway, conn, err = dialGateway(cfg); err != nil {
+			return changes, err
+		}
+	}
+	var update *sink.Update
+	if redial || config.ChangedUnder(changes, "queue", "sinks") {
+		if update, err = r.sinks.Prepare(cfg, gateway); err != nil {
+			if redial && conn != nil {
+				conn.Close()
+			}
+			return changes, err
+		}
+	}
+	if err := r.supervisor.Apply(cfg); err != nil {
+		if update != nil {
+			update.Abort()
+		}
+		if redial && conn != nil {
+			conn.Close()
+		}
+		return changes, err
+	}
+
+	r.pipeline.Store(processors)
+	if update != nil {
+		update.Commit()
+		previous := r.conn
+		go func() {
+			// The replaced sinks drain first, a replaced gateway sink needing its connection
+			if err := update.Retire(context.Background()); err != nil {
+				log.Error().Err(err).Msg("Error closing replaced sinks")
+			}
+			if redial && previous != nil {
+				previous.Close()
+			}
+		}()
+	}
+	r.gateway, r.conn = gateway, conn
+	r.current = cfg
+
+	if config.ChangedUnder(changes, "metrics") {
+		r.serveMetrics(cfg)
+	}
+	if redial || config.ChangedUnder(changes, "remote") {
+		r.watchRemote(cfg)
+	}
+	return changes, nil
+}
+
+// serveMetrics serves the metrics on the address of cfg, if any, once the
+// server on the previous address stopped. r.mu must be held.
+func (r *reloader) serveMetrics(cfg *config.Config) {
+	if r.stopMetrics != nil {
+		r.stopMetrics()
+		<-r.metricsDone
+		r.stopMetrics = nil
+	}
+	if cfg.Metrics.Addr == "" {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	done := make(chan struct{})
+	r.stopMetrics, r.metricsDone = cancel, done
+	go func() {
+		defer close(done)
+		if err := metrics.Serve(ctx, cfg.Metrics.Addr); err != nil {
+			log.Error().Err(err).Msg("Error serving metrics")
+		}
+	}()
+}
+
+// watchRemote watches the remote configuration from the current gateway, if
+// enabled by cfg, in place of the previous watch. The previous watch may be
+// waiting for r.mu to apply a configuration, which it then rejects as its
+// context is done. r.mu must be held.
+func (r *reloader) watchRemote(cfg *config.Config) {
+	if r.stopRemote != nil {
+		r.stopRemote()
+		r.stopRemote = nil
+	}
+	if !cfg.Remote.Enabled {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.stopRemote = cancel
+	go eventgateway.WatchRemoteConfig(ctx, r.gateway, cfg, func(remote config.Remote) error {
+		return r.applyRemote(ctx, remote)
+	})
+}
+
+func changeStrings(changes []config.Change) []string {
+	strs := make([]string, 0, len(changes))
+	for _, change := range changes {
+		strs = append(strs, change.String())
+	}
+	return strs
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/pixie"
+	"orbservability/observer/pkg/processor"
+	"orbservability/observer/pkg/sink"
+)
+
+// run is the daemon: it executes the scripts against the sources and sends
+// the events to the sinks until interrupted.
+func run(args []string) error {
+	fs := newFlagSet("run")
+	flags := config.RegisterFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Registered before anything starts, as SIGHUP terminates the process until then
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	// Load Config
+	cfg, err := flags.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	logWarnings(cfg)
+	processors, err := processor.New(cfg.Processors)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	pipeline := processor.NewPipeline(processors)
+
+	// Create a Pixie client per source
+	sources, err := pixie.ConnectSources(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("creating Pixie client: %w", err)
+	}
+
+	// Initialize gRPC client
+	eventGateway, grpcConn, err := dialGateway(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Open the sinks, each behind its own queue. They outlive ctx so that
+	// queued events can be drained on shutdown.
+	sinks, err := sink.Open(cfg, eventGateway)
+	if err != nil {
+		if grpcConn != nil {
+			grpcConn.Close()
+		}
+		return fmt.Errorf("opening sinks: %w", err)
+	}
+	sinkFailure := cancelOnFailure(ctx, cancel, sinks)
+
+	// Execute PxL scripts and handle records, serve metrics and reload the
+	// configuration on SIGHUP and, if enabled, whenever the gateway pushes one.
+	// The reloader owns the sinks and the gateway connection from here on.
+	supervisor := pixie.NewSupervisor(sinks, pipeline)
+	reloader := newReloader(ctx, flags, cfg, supervisor, pipeline, sinks, eventGateway, grpcConn)
+	defer reloader.close()
+	supervisor.Start(ctx, sources, cfg)
+	reloader.start()
+	go reloader.reloadOnHangup(hangup)
+	err = supervisor.Wait()
+	if failure := sinkFailure(); failure != nil {
+		return fmt.Errorf("sending events: %w", failure)
+	}
+	if err != nil {
+		return fmt.Errorf("handling records: %w", err)
+	}
+	return nil
+}
+
+// cancelOnFailure cancels ctx once a sink that fails the observer fails. The
+// returned function reports that failure, if any, once ctx is done.
+func cancelOnFailure(ctx context.Context, cancel context.CancelFunc, sinks *sink.Set) func() error {
+	failed := make(chan error, 1)
+	go func() {
+		select {
+		case err := <-sinks.Failed():
+			log.Error().Err(err).Msg("Error sending events")
+			failed <- err
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return func() error {
+		select {
+		case err := <-failed:
+			return err
+		default:
+			return nil
+		}
+	}
+}
+
+// logWarnings logs the settings of cfg that are valid but most likely a mistake.
+func logWarnings(cfg *config.Config) {
+	for _, w := range cfg.Warnings {
+		log.Warn().Str("setting", w.Key).Str("origin", w.Origin).Msg(w.Message)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+	"orbservability/observer/pkg/sink/stdout"
+)
+
+// tail subscribes to the subscriptions sink of a running observer and prints
+// the events it serves like a stdout sink, until interrupted.
+func tail(args []string) error {
+	out := config.DefaultStdoutSink()
+	fs := newFlagSet("tail")
+	addr := fs.String("addr", "", "address of the subscriptions sink, host:port or unix:/path, defaults to the one in the configuration")
+	namespaces := fs.String("namespaces", "", "comma separated namespaces to print, all when empty")
+	services := fs.String("services", "", "comma separated services to print, all when empty")
+	protocols := fs.String("protocols", "", "comma separated protocols to print, all when empty")
+	fs.StringVar(&out.Format, "format", out.Format, `"table" or "compact"`)
+	columns := fs.String("columns", strings.Join(out.Columns, ","), "comma separated columns to print, of "+strings.Join(config.StdoutColumns, ", "))
+	fs.StringVar(&out.Color, "color", out.Color, `"auto", "always" or "never"`)
+	flags := config.RegisterFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	out.Columns = splitList(*columns)
+	if err := out.Validate(); err != nil {
+		return usageErrorf(fs, "%v", err)
+	}
+
+	if *addr == "" {
+		cfg, err := flags.Load()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		for _, sc := range cfg.Sinks {
+			if sc.Type == config.SinkServer {
+				*addr = sc.Subscriptions.Addr
+				break
+			}
+		}
+		if *addr == "" {
+			return usageErrorf(fs, "-addr is required when no subscriptions sink is configured")
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, *addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stream, err := pb.NewSubscriptionServiceClient(conn).Subscribe(ctx, &pb.SubscribeRequest{
+		Namespaces: splitList(*namespaces),
+		Services:   splitList(*services),
+		Protocols:  splitList(*protocols),
+	})
+	if err != nil {
+		return err
+	}
+
+	printer := stdout.New(out)
+	for {
+		e, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil && status.Code(err) == codes.Canceled {
+				return nil
+			}
+			return fmt.Errorf("subscription to %s: %w", *addr, err)
+		}
+		if err := errors.Join(printer.Send(ctx, e), printer.Flush(ctx)); err != nil {
+			return err
+		}
+	}
+}
+
+// splitList splits a comma separated list, dropping empty entries.
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// observerVersion is set when building a release, with
+// -ldflags "-X main.observerVersion=v1.2.3".
+var observerVersion = "dev"
+
+// version prints the version of the observer, and the commit and Go version
+// it was built from.
+func version(args []string) error {
+	fs := newFlagSet("version")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	fmt.Printf("observer %s\n", observerVersion)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		settings := map[string]string{}
+		for _, s := range info.Settings {
+			settings[s.Key] = s.Value
+		}
+		if revision := settings["vcs.revision"]; revision != "" {
+			if settings["vcs.modified"] == "true" {
+				revision += " (modified)"
+			}
+			fmt.Printf("commit  %s\n", revision)
+		}
+		if at := settings["vcs.time"]; at != "" {
+			fmt.Printf("date    %s\n", at)
+		}
+	}
+	fmt.Printf("go      %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Delay returns the delay before retry attempt n, counting from 1.
+// The base delay doubles with every attempt up to the maximum, and the
+// second half of the delay is jittered so that retries do not happen in lockstep.
+func (b BackoffConfig) Delay(attempt int) time.Duration {
+	delay := b.Base.Duration()
+	for i := 1; i < attempt && delay < b.Max.Duration(); i++ {
+		delay *= 2
+	}
+	if delay > b.Max.Duration() {
+		delay = b.Max.Duration()
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
@@ -0,0 +1,361 @@
+package config
+
+// Config is the fully resolved observer configuration.
+// Every field can be set in the YAML configuration file under the key in its
+// yaml tag; see Load for the order in which sources are applied.
+type Config struct {
+	Gateway    GatewayConfig     `yaml:"gateway"`
+	Sources    []PixieSource     `yaml:"sources"`
+	Scripts    []Script          `yaml:"scripts"`
+	Execution  ExecutionConfig   `yaml:"execution"`
+	Queue      QueueConfig       `yaml:"queue"` // Default queue of every sink
+	Sinks      []SinkConfig      `yaml:"sinks"`
+	Processors []ProcessorConfig `yaml:"processors"`
+	Metrics    MetricsConfig     `yaml:"metrics"`
+	Remote     RemoteConfig      `yaml:"remote"`
+
+	File     string    `yaml:"-"` // Path of the configuration file, if one was loaded
+	Warnings []Problem `yaml:"-"` // Settings that are valid but most likely a mistake
+
+	origins origins // Where each setting came from, see Origin
+}
+
+// GatewayConfig is the Orbservability event gateway events are streamed to.
+type GatewayConfig struct {
+	URL         string    `yaml:"url"`
+	TLS         TLSConfig `yaml:"tls"`
+	SendTimeout Duration  `yaml:"send_timeout"` // Longest a single send may block before the stream is reset, 0 disables
+	APIKey      Secret    `yaml:"api_key"`      // Set on every event sent to the gateway
+	APIKeyFile  string    `yaml:"api_key_file"` // Mounted secret holding APIKey
+}
+
+// PixieSource is a single Vizier/PEM endpoint the PxL scripts are executed against.
+type PixieSource struct {
+	Name       string    `yaml:"name"` // Tags every event produced by this source
+	URL        string    `yaml:"url"`
+	VizierHost string    `yaml:"vizier_host"`
+	TLS        TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig holds the settings used to connect to a remote endpoint over TLS.
+type TLSConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	CAFile     string `yaml:"ca_file"`     // PEM encoded CA bundle used to verify the server
+	CertFile   string `yaml:"cert_file"`   // Optional PEM encoded client certificate
+	KeyFile    string `yaml:"key_file"`    // Optional PEM encoded client key
+	ServerName string `yaml:"server_name"` // Overrides the host name used to verify the server certificate
+}
+
+// Script is a PxL script executed against every source.
+// The script is read from Path unless PxL is given inline.
+type Script struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+	PxL  string `yaml:"pxl"`
+
+	loaded bool // PxL was read from Path
+}
+
+// ExecutionConfig controls how scripts are re-executed.
+type ExecutionConfig struct {
+	Interval      Duration      `yaml:"interval"`        // Delay between executions
+	IdleTimeout   Duration      `yaml:"idle_timeout"`    // Minimum time without data before a stream counts as stalled, 0 disables
+	MaxErrorCount int           `yaml:"max_error_count"` // Failed executions in a row tolerated before a source is restarted
+	Backoff       BackoffConfig `yaml:"backoff"`
+
+	StreamSleep *int `yaml:"stream_sleep"` // Deprecated: seconds between executions, replaced by Interval when loaded
+}
+
+// BackoffConfig is an exponential backoff used between retries.
+type BackoffConfig struct {
+	Base Duration `yaml:"base"` // Delay before the first retry, doubled for each further retry
+	Max  Duration `yaml:"max"`
+}
+
+// QueueConfig bounds the events buffered between Pixie and a sink.
+type QueueConfig struct {
+	Size          int      `yaml:"size"`
+	Overflow      string   `yaml:"overflow"`       // "block" applies backpressure to Pixie, "drop" discards new events
+	DrainDeadline Duration `yaml:"drain_deadline"` // Time allowed to send queued events on shutdown
+	FlushInterval Duration `yaml:"flush_interval"` // Longest an event may be buffered by the sink
+}
+
+const (
+	OverflowBlock = "block"
+	OverflowDrop  = "drop"
+)
+
+// SinkConfig is a destination events are sent to, each behind its own queue.
+// Type selects which of the type specific sections applies.
+type SinkConfig struct {
+	Name    string      `yaml:"name"` // Defaults to the type
+	Type    string      `yaml:"type"`
+	Queue   QueueConfig `yaml:"queue"`    // Unset fields are taken from the top level queue
+	OnError string      `yaml:"on_error"` // "fail" stops the observer, "drop" discards the events that could not be sent
+
+	File          FileSinkConfig          `yaml:"file"`
+	Stdout        StdoutSinkConfig        `yaml:"stdout"`
+	OTLP          OTLPSinkConfig          `yaml:"otlp"`
+	Metrics       MetricsSinkConfig       `yaml:"metrics"`
+	Webhook       WebhookSinkConfig       `yaml:"webhook"`
+	Loki          LokiSinkConfig          `yaml:"loki"`
+	OpenSearch    OpenSearchSinkConfig    `yaml:"opensearch"`
+	Parquet       ParquetSinkConfig       `yaml:"parquet"`
+	Kafka         KafkaSinkConfig         `yaml:"kafka"`
+	Subscriptions SubscriptionsSinkConfig `yaml:"subscriptions"`
+}
+
+const (
+	SinkGateway = "gateway" // The event gateway, configured by Config.Gateway
+	SinkFile    = "file"
+	SinkStdout  = "stdout"
+	SinkTraces  = "otlp_traces"   // Spans sent to an OpenTelemetry collector, configured by SinkConfig.OTLP
+	SinkLogs    = "otlp_logs"     // Log records sent to an OpenTelemetry collector, configured by SinkConfig.OTLP
+	SinkMetrics = "metrics"       // Request metrics served on the metrics endpoint, configured by SinkConfig.Metrics
+	SinkWebhook = "webhook"       // Batches posted to an HTTP endpoint, configured by SinkConfig.Webhook
+	SinkLoki    = "loki"          // Log lines pushed to Grafana Loki, configured by SinkConfig.Loki
+	SinkSearch  = "opensearch"    // Documents indexed into OpenSearch or Elasticsearch, configured by SinkConfig.OpenSearch
+	SinkParquet = "parquet"       // Columnar archive files, configured by SinkConfig.Parquet
+	SinkKafka   = "kafka"         // Records produced to a Kafka topic, configured by SinkConfig.Kafka
+	SinkServer  = "subscriptions" // Streamed to local gRPC subscribers, configured by SinkConfig.Subscriptions
+)
+
+// StdoutSinkConfig prints events to the terminal, for watching traffic live.
+type StdoutSinkConfig struct {
+	Format   string   `yaml:"format"`    // "table" aligns columns under a header, "compact" prints a line per event
+	Columns  []string `yaml:"columns"`   // Printed in order, see StdoutColumns
+	MaxWidth int      `yaml:"max_width"` // Longer values are truncated
+	Color    string   `yaml:"color"`     // "auto" colours output to a terminal, "always" or "never"
+}
+
+const (
+	FormatTable   = "table"
+	FormatCompact = "compact"
+
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// StdoutColumns lists the columns a stdout sink can print.
+var StdoutColumns = []string{"time", "source", "namespace", "service", "side", "remote", "protocol", "latency", "request", "status", "upid"}
+
+// OTLPSinkConfig is an OpenTelemetry collector events are exported to.
+type OTLPSinkConfig struct {
+	Protocol    string            `yaml:"protocol"`     // "grpc" or "http" (binary protobuf)
+	Endpoint    string            `yaml:"endpoint"`     // host:port for grpc, base URL such as http://collector:4318 for http
+	TLS         TLSConfig         `yaml:"tls"`          // Used by grpc, and by http for https endpoints
+	Headers     map[string]Secret `yaml:"headers"`      // Sent with every export, e.g. for authentication
+	HeaderFiles map[string]string `yaml:"header_files"` // Mounted secrets holding header values
+	Timeout     Duration          `yaml:"timeout"`      // Longest a single export may take
+	BatchSize   int               `yaml:"batch_size"`   // Events exported together, or sooner on flush
+	Retry       RetryConfig       `yaml:"retry"`        // Exports the collector may accept later
+}
+
+// RetryConfig retries a failed request with exponential backoff.
+type RetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts"` // Including the first attempt, 1 disables retries
+	Backoff     BackoffConfig `yaml:"backoff"`
+}
+
+// MetricsSinkConfig derives request rate, error and duration (RED) metrics
+// from events. The values of the namespace, service and remote service labels
+// are limited to their allow-list, or to the first MaxValues seen when the
+// list is empty; any other value is counted under the overflow value "_other".
+type MetricsSinkConfig struct {
+	Namespaces     []string  `yaml:"namespaces"`
+	Services       []string  `yaml:"services"`
+	RemoteServices []string  `yaml:"remote_services"`
+	MaxValues      int       `yaml:"max_values"` // Values kept of each label without an allow-list
+	Buckets        []float64 `yaml:"buckets"`    // Upper bounds of the duration histogram, in seconds
+}
+
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+)
+
+// WebhookSinkConfig posts batches of events as JSON to an HTTP endpoint.
+type WebhookSinkConfig struct {
+	URL             string            `yaml:"url"`
+	Format          string            `yaml:"format"` // "json" posts an array of events, "ndjson" an event per line
+	TLS             TLSConfig         `yaml:"tls"`    // Used by https URLs
+	Headers         map[string]Secret `yaml:"headers"`
+	HeaderFiles     map[string]string `yaml:"header_files"`     // Mounted secrets holding header values
+	Secret          Secret            `yaml:"secret"`           // Signs every request with HMAC-SHA256, unset disables signing
+	SecretFile      string            `yaml:"secret_file"`      // Mounted secret holding Secret
+	SignatureHeader string            `yaml:"signature_header"` // Carries the signature as "sha256=<hex>"
+	Timeout         Duration          `yaml:"timeout"`          // Longest a single request may take
+	BatchSize       int               `yaml:"batch_size"`       // Events posted together, or sooner on flush
+	Retry           RetryConfig       `yaml:"retry"`            // Requests answered with 429 or 5xx
+	Breaker         BreakerConfig     `yaml:"breaker"`
+}
+
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatLogfmt = "logfmt"
+)
+
+// LokiSinkConfig pushes events to the Grafana Loki push API, as log lines in
+// streams identified by a few low cardinality labels.
+type LokiSinkConfig struct {
+	URL          string            `yaml:"url"`       // Base URL, e.g. http://loki:3100
+	TenantID     string            `yaml:"tenant_id"` // Sent as X-Scope-OrgID to a multi-tenant Loki
+	TLS          TLSConfig         `yaml:"tls"`       // Used by https URLs
+	Headers      map[string]Secret `yaml:"headers"`
+	HeaderFiles  map[string]string `yaml:"header_files"`  // Mounted secrets holding header values
+	Labels       []string          `yaml:"labels"`        // Stream labels taken from every event, see LokiLabels
+	StaticLabels map[string]string `yaml:"static_labels"` // Added to every stream
+	Format       string            `yaml:"format"`        // Line format, "logfmt" or "json"
+	Timeout      Duration          `yaml:"timeout"`       // Longest a single push may take
+	BatchBytes   int               `yaml:"batch_bytes"`   // Size of the lines pushed together, or sooner on flush
+	Retry        RetryConfig       `yaml:"retry"`         // Pushes answered with 429 or 5xx
+}
+
+// OpenSearchSinkConfig indexes events as documents into OpenSearch or
+// Elasticsearch with the _bulk API.
+type OpenSearchSinkConfig struct {
+	URL          string            `yaml:"url"` // Base URL of the cluster, e.g. https://opensearch:9200
+	TLS          TLSConfig         `yaml:"tls"` // Used by https URLs
+	Username     string            `yaml:"username"`
+	Password     Secret            `yaml:"password"`
+	PasswordFile string            `yaml:"password_file"` // Mounted secret holding Password
+	Headers      map[string]Secret `yaml:"headers"`
+	HeaderFiles  map[string]string `yaml:"header_files"` // Mounted secrets holding header values
+	Index        string            `yaml:"index"`        // Index name, with a Go time layout in braces replaced by the event's UTC date, e.g. observer-{2006.01.02}
+	Template     string            `yaml:"template"`     // Name of the index template installed at startup for the indices, empty skips it
+	Timeout      Duration          `yaml:"timeout"`      // Longest a single request may take
+	BatchSize    int               `yaml:"batch_size"`   // Events indexed together, or sooner on flush
+	Retry        RetryConfig       `yaml:"retry"`        // Requests and documents rejected with 429 or 5xx
+}
+
+// KafkaSinkConfig produces events as records to a Kafka topic. Records are
+// written idempotently when Acks is "all", so retries neither duplicate nor
+// reorder them within a partition.
+type KafkaSinkConfig struct {
+	Brokers     []string        `yaml:"brokers"` // host:port of the brokers to bootstrap from
+	Topic       string          `yaml:"topic"`
+	ClientID    string          `yaml:"client_id"`
+	TLS         TLSConfig       `yaml:"tls"`
+	SASL        KafkaSASLConfig `yaml:"sasl"`
+	Format      string          `yaml:"format"`      // "protobuf" writes the serialized pb.PixieEvent, "json" its protojson
+	Key         string          `yaml:"key"`         // Event field the records are keyed by, see KafkaKeys, empty leaves records unkeyed
+	Acks        string          `yaml:"acks"`        // "all" in-sync replicas, the partition "leader" or "none"
+	Partitioner string          `yaml:"partitioner"` // See KafkaPartitioners
+	Compression string          `yaml:"compression"` // See KafkaCompressions
+	Linger      Duration        `yaml:"linger"`      // Time a batch may wait for more records, 0 sends records as they come
+	Timeout     Duration        `yaml:"timeout"`     // Longest a record may take to be produced, retries included
+	Retry       RetryConfig     `yaml:"retry"`       // Produce requests failing with a retriable error
+}
+
+// KafkaSASLConfig authenticates to the brokers with SASL, unless Mechanism is empty.
+type KafkaSASLConfig struct {
+	Mechanism    string `yaml:"mechanism"` // "plain", "scram-sha-256" or "scram-sha-512"
+	Username     string `yaml:"username"`
+	Password     Secret `yaml:"password"`
+	PasswordFile string `yaml:"password_file"` // Mounted secret holding Password
+}
+
+const (
+	FormatProtobuf = "protobuf"
+
+	AcksAll    = "all"
+	AcksLeader = "leader"
+	AcksNone   = "none"
+)
+
+// KafkaKeys lists the event fields a Kafka sink can key records by.
+var KafkaKeys = []string{"upid", "namespace", "service", "remote_service", "protocol", "source"}
+
+// KafkaPartitioners lists the partitioners of a Kafka sink: "hash" sends
+// records with the same key to the same partition, with the murmur2 hash of
+// the Java client, "round_robin" spreads records evenly regardless of their
+// key and "least_backup" favours the partitions with the fewest records in
+// flight.
+var KafkaPartitioners = []string{"hash", "round_robin", "least_backup"}
+
+// KafkaCompressions lists the compression codecs of a Kafka sink.
+var KafkaCompressions = []string{"none", "gzip", "snappy", "lz4", "zstd"}
+
+// SubscriptionsSinkConfig serves the events to local clients subscribing to
+// the SubscriptionService over gRPC, e.g. other agents on the node. The
+// server has neither TLS nor authentication, so it listens only on a Unix
+// socket or a loopback address unless AllowRemote is set.
+type SubscriptionsSinkConfig struct {
+	Addr           string `yaml:"addr"`            // host:port, or unix:/path for a Unix socket
+	AllowRemote    bool   `yaml:"allow_remote"`    // Allows Addr to be reachable from other hosts
+	Buffer         int    `yaml:"buffer"`          // Events buffered per subscriber, further events are dropped until it catches up
+	MaxSubscribers int    `yaml:"max_subscribers"` // Further subscribers are refused
+}
+
+// LokiLabels lists the event fields a Loki sink can use as stream labels.
+var LokiLabels = []string{"source", "namespace", "service", "protocol", "side"}
+
+// BreakerConfig stops requests to an endpoint that keeps failing. After
+// Failures consecutive failed batches, batches are dropped without a request
+// for Cooldown, after which one batch is tried again.
+type BreakerConfig struct {
+	Failures int      `yaml:"failures"` // Negative disables the breaker, 0 takes the default
+	Cooldown Duration `yaml:"cooldown"`
+}
+
+// FileSinkConfig writes events as JSON lines to a local file.
+// Rotated files are named after the file with the time of rotation and a
+// sequence number appended, e.g. events-20240101T120000.000Z-1.jsonl.
+type FileSinkConfig struct {
+	Path      string   `yaml:"path"`
+	MaxSizeMB int      `yaml:"max_size_mb"` // Rotate once the file would grow beyond, negative disables
+	MaxAge    Duration `yaml:"max_age"`     // Rotate once the file is older, 0 disables
+	Compress  bool     `yaml:"compress"`    // Gzip rotated files
+	MaxFiles  int      `yaml:"max_files"`   // Rotated files kept, negative keeps every file
+}
+
+// ParquetSinkConfig archives events in Parquet files under Dir, partitioned by
+// protocol and hour, e.g. protocol=http/date=2024-01-01/hour=13/part-20240101T130000.000Z-1.parquet.
+// Files are written under a .tmp name and renamed once closed.
+type ParquetSinkConfig struct {
+	Dir         string   `yaml:"dir"`
+	MaxRows     int      `yaml:"max_rows"`    // Close a file once it holds this many rows
+	MaxAge      Duration `yaml:"max_age"`     // Close a file once it has been open this long, checked on flush
+	Compression string   `yaml:"compression"` // "zstd", "snappy", "gzip" or "none"
+}
+
+// ParquetCompressions lists the compression codecs of a Parquet sink.
+var ParquetCompressions = []string{"zstd", "snappy", "gzip", "none"}
+
+const (
+	OnErrorFail = "fail"
+	OnErrorDrop = "drop"
+)
+
+// ProcessorConfig is one step of the processing chain applied to every event.
+// Type selects which of the remaining fields apply.
+type ProcessorConfig struct {
+	Type string `yaml:"type"` // "filter", "sample" or "rate_limit"
+
+	// filter
+	Namespaces        []string `yaml:"namespaces"`
+	ExcludeNamespaces []string `yaml:"exclude_namespaces"`
+	Services          []string `yaml:"services"`
+	ExcludeServices   []string `yaml:"exclude_services"`
+	Protocols         []string `yaml:"protocols"`
+
+	// sample and rate_limit
+	Rate float64 `yaml:"rate"` // Fraction of events kept, greater than 0 and at most 1, or events kept per second
+
+	// rate_limit
+	Burst int `yaml:"burst"` // Events kept at once after a quiet period, defaults to the rate rounded up
+}
+
+// MetricsConfig controls the Prometheus endpoint.
+type MetricsConfig struct {
+	Addr string `yaml:"addr"` // Listen address, empty disables
+}
+
+// RemoteConfig lets the event gateway manage the scripts and processors.
+type RemoteConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	ObserverID string        `yaml:"observer_id"` // Identifies the observer to the gateway, defaults to the host name
+	Backoff    BackoffConfig `yaml:"backoff"`     // Between attempts to reach the gateway
+}
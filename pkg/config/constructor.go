@@ -1,23 +1,17 @@
 package config
 
 import (
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
-type Config struct {
-	OrbservabilityURL string
-	PixieURL          string
-	VizierHost        string
-	PxLFilePath       string
-	PxL               string
-	PixieStreamSleep  int
-	MaxErrorCount     int
-}
-
 // NewConfig creates a new Config struct with default configuration.
-// It attempts to override these defaults with environment variables if they are set.
+// It attempts to override these defaults with the configuration file named by
+// $OBSERVER_CONFIG and then with environment variables if they are set.
 //
 // Returns:
 //   - A pointer to an Config struct which contains configuration settings.
@@ -30,56 +24,530 @@ type Config struct {
 //		// handle error
 //	}
 func NewConfig() (*Config, error) {
-	config := &Config{
-		OrbservabilityURL: "",                    // Default URL
-		PixieURL:          "127.0.0.1:12345",     // Default URL
-		VizierHost:        "localhost",           // Default Host
-		PxLFilePath:       "./config/config.pxl", // Default script path
-		PxL:               "",                    // PxL script
-		PixieStreamSleep:  10,                    // Default sleep time in seconds
-		MaxErrorCount:     3,                     // Default maximum error count
+	return Load(nil)
+}
+
+// Load resolves the configuration from, in increasing order of precedence:
+//
+//  1. Defaults
+//  2. The YAML file given by -config or $OBSERVER_CONFIG
+//  3. Environment variables
+//  4. Command line flags in args
+//
+// Settings that replace a whole list (sources, scripts) are applied before
+// settings that adjust every entry of a list (e.g. $VIZIER_HOST), so a
+// -pixie-url flag still picks up $VIZIER_HOST.
+func Load(args []string) (*Config, error) {
+	config, p, err := load(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.err(); err != nil {
+		return nil, err
 	}
+	return config, nil
+}
 
-	// Override defaults if environment variables are set
+// Flags are the configuration flags, registered by every command next to its
+// own flags.
+type Flags struct {
+	path   *string
+	values *flagValues
+}
 
-	orb_url := os.Getenv("ORBSERVABILITY_URL")
-	if orb_url != "" {
-		config.OrbservabilityURL = orb_url
-	} else {
-		return nil, fmt.Errorf("ORBSERVABILITY_URL environment variable not set")
+// RegisterFlags registers the configuration flags on fs. Call Load once fs
+// has been parsed.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	return &Flags{
+		path:   fs.String("config", "", "path to the YAML configuration file (env OBSERVER_CONFIG)"),
+		values: registerFlags(fs),
+	}
+}
+
+// Load loads the configuration with the parsed flags applied.
+func (f *Flags) Load() (*Config, error) {
+	config, p, err := f.load()
+	if err != nil {
+		return nil, err
 	}
-	if url := os.Getenv("PIXIE_URL"); url != "" {
-		config.PixieURL = url
+	if err := p.err(); err != nil {
+		return nil, err
 	}
-	if host := os.Getenv("VIZIER_HOST"); host != "" {
-		config.VizierHost = host
+	return config, nil
+}
+
+// Reload loads the configuration again with the parsed flags applied and
+// compares it with current. The changes are returned even when the new
+// configuration is invalid, so that a rejected reload can be explained.
+func (f *Flags) Reload(current *Config) (*Config, []Change, error) {
+	config, p, err := f.load()
+	if err != nil {
+		return nil, nil, err
 	}
-	if path := os.Getenv("PXL_FILE_PATH"); path != "" {
-		config.PxLFilePath = path
+	changes := Diff(current, config)
+	if err := p.err(); err != nil {
+		return nil, changes, err
 	}
-	if sleep := os.Getenv("PIXIE_STREAM_SLEEP"); sleep != "" {
-		val, err := strconv.Atoi(sleep)
-		if err != nil {
-			return nil, fmt.Errorf("error: %w", err)
-		} else {
-			config.PixieStreamSleep = val
+	return config, changes, nil
+}
+
+// load resolves the configuration from args, returning the problems found alongside it.
+func load(args []string) (*Config, *problems, error) {
+	fs := flag.NewFlagSet("observer", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	return flags.load()
+}
+
+// load resolves the configuration, returning the problems found alongside it.
+// A script that cannot be read is only an error when there are no other problems.
+func (f *Flags) load() (*Config, *problems, error) {
+	config := defaultConfig()
+	p := &problems{origins: config.origins}
+
+	path := *f.path
+	if path == "" {
+		path = os.Getenv("OBSERVER_CONFIG")
+	}
+	if path != "" {
+		if err := loadFile(path, config); err != nil {
+			return nil, nil, err
 		}
+		resolveDeprecated(config, p)
 	}
-	if maxErr := os.Getenv("PIXIE_ERROR_MAX"); maxErr != "" {
-		val, err := strconv.Atoi(maxErr)
-		if err != nil {
-			return nil, fmt.Errorf("error: %w", err)
-		} else {
-			config.MaxErrorCount = val
+
+	for _, phase := range []int{phaseList, phaseField} {
+		applyEnv(config, phase, p)
+		f.values.apply(config, phase, p)
+	}
+
+	resolve(config)
+	resolveSecrets(config, p)
+	config.validate(p)
+	config.Warnings = p.warnings
+	if len(p.list) > 0 {
+		readScripts(config) // Best effort, so that a diff against the current scripts is meaningful
+		return config, p, nil
+	}
+
+	if err := readScripts(config); err != nil {
+		return nil, nil, err
+	}
+	return config, p, nil
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		origins: origins{},
+		Gateway: GatewayConfig{
+			URL:         "",                         // Required
+			SendTimeout: Duration(30 * time.Second), // Default send timeout
+		},
+		Execution: ExecutionConfig{
+			Interval:      Duration(10 * time.Second), // Default sleep time between executions
+			IdleTimeout:   Duration(time.Minute),      // Default stalled stream timeout, 0 disables
+			MaxErrorCount: 3,                          // Default maximum error count
+			Backoff: BackoffConfig{
+				Base: Duration(time.Second),     // Default first retry delay
+				Max:  Duration(2 * time.Minute), // Default longest retry delay
+			},
+		},
+		Queue: QueueConfig{
+			Size:          1000,                       // Default events buffered for the gateway
+			Overflow:      OverflowBlock,              // Default to backpressure rather than data loss
+			DrainDeadline: Duration(10 * time.Second), // Default time to flush the queue on shutdown
+			FlushInterval: Duration(time.Second),      // Default longest time an event is buffered by a sink
+		},
+		Metrics: MetricsConfig{
+			Addr: ":9090", // Default metrics listen address, empty disables
+		},
+		Remote: RemoteConfig{
+			Backoff: BackoffConfig{
+				Base: Duration(time.Second), // Default first retry delay
+				Max:  Duration(time.Minute), // Default longest retry delay
+			},
+		},
+	}
+}
+
+const (
+	defaultPixieURL    = "127.0.0.1:12345"
+	defaultVizierHost  = "localhost"
+	defaultPxLFilePath = "./config/config.pxl"
+)
+
+// resolveDeprecated moves the values of deprecated file keys to the settings
+// that replaced them, so that environment variables and flags still override them.
+func resolveDeprecated(config *Config, p *problems) {
+	if sleep := config.Execution.StreamSleep; sleep != nil {
+		config.Execution.StreamSleep = nil
+		if _, found := config.origins["execution.interval"]; found {
+			p.add("execution.stream_sleep", "deprecated alias of execution.interval, which is also set")
+			return
+		}
+		config.Execution.Interval = Duration(time.Duration(*sleep) * time.Second)
+		config.origins.set("execution.interval", config.origins.of("execution.stream_sleep"))
+		p.warn("execution.interval", "execution.stream_sleep is deprecated, use execution.interval")
+	}
+}
+
+// resolve fills in per-entry defaults.
+func resolve(config *Config) {
+	if config.Gateway.TLS.CAFile != "" {
+		config.Gateway.TLS.Enabled = true
+	}
+
+	if len(config.Sources) == 0 {
+		config.Sources = []PixieSource{{URL: defaultPixieURL}}
+	}
+	for i := range config.Sources {
+		source := &config.Sources[i]
+		if source.Name == "" {
+			source.Name = source.URL
+		}
+		if source.VizierHost == "" {
+			source.VizierHost = defaultVizierHost
+		}
+		if source.TLS.CAFile != "" {
+			source.TLS.Enabled = true
+		}
+	}
+
+	if len(config.Sinks) == 0 {
+		config.Sinks = []SinkConfig{{Type: SinkGateway}}
+	}
+	for i := range config.Sinks {
+		sink := &config.Sinks[i]
+		if sink.Name == "" {
+			sink.Name = sink.Type
+		}
+		if sink.OnError == "" {
+			// Only the gateway, the primary destination, is fatal by default
+			sink.OnError = OnErrorDrop
+			if sink.Type == SinkGateway {
+				sink.OnError = OnErrorFail
+			}
+		}
+		inheritQueue(&sink.Queue, config.Queue)
+		switch sink.Type {
+		case SinkFile:
+			resolveFileSink(&sink.File)
+		case SinkStdout:
+			resolveStdoutSink(&sink.Stdout)
+		case SinkTraces, SinkLogs:
+			resolveOTLPSink(&sink.OTLP)
+		case SinkMetrics:
+			resolveMetricsSink(&sink.Metrics)
+		case SinkWebhook:
+			resolveWebhookSink(&sink.Webhook)
+		case SinkLoki:
+			resolveLokiSink(&sink.Loki)
+		case SinkSearch:
+			resolveOpenSearchSink(&sink.OpenSearch)
+		case SinkParquet:
+			resolveParquetSink(&sink.Parquet)
+		case SinkKafka:
+			resolveKafkaSink(&sink.Kafka)
+		case SinkServer:
+			resolveSubscriptionsSink(&sink.Subscriptions)
+		}
+	}
+
+	if config.Remote.ObserverID == "" {
+		config.Remote.ObserverID, _ = os.Hostname()
+	}
+
+	if len(config.Scripts) == 0 {
+		config.Scripts = []Script{{Path: defaultPxLFilePath}}
+	}
+	for i := range config.Scripts {
+		script := &config.Scripts[i]
+		if script.Name == "" {
+			script.Name = filepath.Base(script.Path)
+		}
+	}
+}
+
+// resolveFileSink fills in the defaults of a file sink.
+func resolveFileSink(f *FileSinkConfig) {
+	if f.MaxSizeMB == 0 {
+		f.MaxSizeMB = 100 // Default size at which files are rotated
+	}
+	if f.MaxFiles == 0 {
+		f.MaxFiles = 10 // Default rotated files kept
+	}
+}
+
+// DefaultStdoutSink returns the settings of a stdout sink left to the defaults.
+func DefaultStdoutSink() StdoutSinkConfig {
+	var s StdoutSinkConfig
+	resolveStdoutSink(&s)
+	return s
+}
+
+// resolveStdoutSink fills in the defaults of a stdout sink.
+func resolveStdoutSink(s *StdoutSinkConfig) {
+	if s.Format == "" {
+		s.Format = FormatTable
+	}
+	if len(s.Columns) == 0 {
+		s.Columns = []string{"time", "source", "namespace", "service", "protocol", "latency", "request", "status"}
+	}
+	if s.MaxWidth == 0 {
+		s.MaxWidth = 48 // Default longest column
+	}
+	if s.Color == "" {
+		s.Color = ColorAuto
+	}
+}
+
+// resolveMetricsSink fills in the defaults of a metrics sink.
+func resolveMetricsSink(m *MetricsSinkConfig) {
+	if m.MaxValues == 0 {
+		m.MaxValues = 100 // Default values per label
+	}
+	if len(m.Buckets) == 0 {
+		m.Buckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	}
+}
+
+// resolveWebhookSink fills in the defaults of a webhook sink.
+func resolveWebhookSink(w *WebhookSinkConfig) {
+	if w.Format == "" {
+		w.Format = FormatJSON
+	}
+	if strings.HasPrefix(w.URL, "https://") {
+		w.TLS.Enabled = true
+	}
+	if w.SignatureHeader == "" {
+		w.SignatureHeader = "X-Observer-Signature"
+	}
+	if w.Timeout == 0 {
+		w.Timeout = Duration(10 * time.Second) // Default request timeout
+	}
+	if w.BatchSize == 0 {
+		w.BatchSize = 100 // Default events per request
+	}
+	resolveRetry(&w.Retry)
+	if w.Breaker.Failures == 0 {
+		w.Breaker.Failures = 5 // Default failed batches before the breaker opens
+	}
+	if w.Breaker.Cooldown == 0 {
+		w.Breaker.Cooldown = Duration(30 * time.Second) // Default time the breaker stays open
+	}
+}
+
+// resolveLokiSink fills in the defaults of a Loki sink.
+func resolveLokiSink(l *LokiSinkConfig) {
+	if strings.HasPrefix(l.URL, "https://") {
+		l.TLS.Enabled = true
+	}
+	if len(l.Labels) == 0 {
+		l.Labels = []string{"namespace", "service", "protocol"}
+	}
+	if l.StaticLabels == nil {
+		l.StaticLabels = map[string]string{"job": "observer"}
+	}
+	if l.Format == "" {
+		l.Format = FormatLogfmt
+	}
+	if l.Timeout == 0 {
+		l.Timeout = Duration(10 * time.Second) // Default push timeout
+	}
+	if l.BatchBytes == 0 {
+		l.BatchBytes = 1 << 20 // Default push size
+	}
+	resolveRetry(&l.Retry)
+}
+
+// resolveOpenSearchSink fills in the defaults of an OpenSearch sink.
+func resolveOpenSearchSink(o *OpenSearchSinkConfig) {
+	if strings.HasPrefix(o.URL, "https://") {
+		o.TLS.Enabled = true
+	}
+	if o.Index == "" {
+		o.Index = "observer-events-{2006.01.02}" // Default daily index
+	}
+	if o.Timeout == 0 {
+		o.Timeout = Duration(30 * time.Second) // Default request timeout
+	}
+	if o.BatchSize == 0 {
+		o.BatchSize = 500 // Default events per bulk request
+	}
+	resolveRetry(&o.Retry)
+}
+
+// resolveParquetSink fills in the defaults of a Parquet sink.
+func resolveParquetSink(p *ParquetSinkConfig) {
+	if p.MaxRows == 0 {
+		p.MaxRows = 100000 // Default rows per file
+	}
+	if p.MaxAge == 0 {
+		p.MaxAge = Duration(10 * time.Minute) // Default time a file is open
+	}
+	if p.Compression == "" {
+		p.Compression = "zstd"
+	}
+}
+
+// resolveKafkaSink fills in the defaults of a Kafka sink.
+func resolveKafkaSink(k *KafkaSinkConfig) {
+	if k.ClientID == "" {
+		k.ClientID = "observer"
+	}
+	if k.Format == "" {
+		k.Format = FormatProtobuf
+	}
+	if k.Acks == "" {
+		k.Acks = AcksAll
+	}
+	if k.Partitioner == "" {
+		k.Partitioner = "hash"
+	}
+	if k.Compression == "" {
+		k.Compression = "snappy"
+	}
+	if k.Timeout == 0 {
+		k.Timeout = Duration(30 * time.Second) // Default delivery timeout
+	}
+	resolveRetry(&k.Retry)
+}
+
+// resolveSubscriptionsSink fills in the defaults of a subscriptions sink.
+func resolveSubscriptionsSink(s *SubscriptionsSinkConfig) {
+	if s.Buffer == 0 {
+		s.Buffer = 1000 // Default events buffered per subscriber
+	}
+	if s.MaxSubscribers == 0 {
+		s.MaxSubscribers = 16
+	}
+}
+
+// resolveOTLPSink fills in the defaults of an OpenTelemetry sink.
+func resolveOTLPSink(o *OTLPSinkConfig) {
+	if o.Protocol == "" {
+		o.Protocol = ProtocolGRPC
+	}
+	if o.Endpoint == "" {
+		switch o.Protocol {
+		case ProtocolGRPC:
+			o.Endpoint = "localhost:4317" // Default collector gRPC receiver
+		case ProtocolHTTP:
+			o.Endpoint = "http://localhost:4318" // Default collector HTTP receiver
 		}
 	}
+	if strings.HasPrefix(o.Endpoint, "https://") || o.TLS.CAFile != "" {
+		o.TLS.Enabled = true
+	}
+	if o.Timeout == 0 {
+		o.Timeout = Duration(10 * time.Second) // Default export timeout
+	}
+	if o.BatchSize == 0 {
+		o.BatchSize = 512 // Default events per export
+	}
+	resolveRetry(&o.Retry)
+}
+
+// resolveRetry fills in the unset fields of a retry policy.
+func resolveRetry(r *RetryConfig) {
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = 5 // Default attempts per request
+	}
+	if r.Backoff.Base == 0 {
+		r.Backoff.Base = Duration(time.Second) // Default first retry delay
+	}
+	if r.Backoff.Max == 0 {
+		r.Backoff.Max = Duration(30 * time.Second) // Default longest retry delay
+	}
+}
+
+// inheritQueue fills the unset fields of q from parent.
+func inheritQueue(q *QueueConfig, parent QueueConfig) {
+	if q.Size == 0 {
+		q.Size = parent.Size
+	}
+	if q.Overflow == "" {
+		q.Overflow = parent.Overflow
+	}
+	if q.DrainDeadline == 0 {
+		q.DrainDeadline = parent.DrainDeadline
+	}
+	if q.FlushInterval == 0 {
+		q.FlushInterval = parent.FlushInterval
+	}
+}
+
+// resolveSecrets reads the secrets configured as files in the config file.
+// Secrets given as "_FILE" environment variables are read by applyEnv.
+func resolveSecrets(config *Config, p *problems) {
+	resolveSecretFile(p, "gateway.api_key", &config.Gateway.APIKey, config.Gateway.APIKeyFile)
 
-	// Read PxL script from file
-	content, err := os.ReadFile(config.PxLFilePath)
+	for i := range config.Sinks {
+		sink := &config.Sinks[i]
+		key := fmt.Sprintf("sinks[%d]", i)
+		resolveHeaderFiles(p, key+".otlp", &sink.OTLP.Headers, sink.OTLP.HeaderFiles)
+		resolveHeaderFiles(p, key+".webhook", &sink.Webhook.Headers, sink.Webhook.HeaderFiles)
+		resolveSecretFile(p, key+".webhook.secret", &sink.Webhook.Secret, sink.Webhook.SecretFile)
+		resolveHeaderFiles(p, key+".loki", &sink.Loki.Headers, sink.Loki.HeaderFiles)
+		resolveHeaderFiles(p, key+".opensearch", &sink.OpenSearch.Headers, sink.OpenSearch.HeaderFiles)
+		resolveSecretFile(p, key+".opensearch.password", &sink.OpenSearch.Password, sink.OpenSearch.PasswordFile)
+		resolveSecretFile(p, key+".kafka.sasl.password", &sink.Kafka.SASL.Password, sink.Kafka.SASL.PasswordFile)
+	}
+}
+
+// resolveSecretFile reads the secret mounted at path into the secret set by
+// key, unless path is empty.
+func resolveSecretFile(p *problems, key string, secret *Secret, path string) {
+	if path == "" {
+		return
+	}
+	if *secret != "" {
+		p.add(key+"_file", "mutually exclusive with %s (%s)", key, p.origins.of(key))
+		return
+	}
+	value, err := readSecretFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("error: %w", err)
+		p.add(key+"_file", "%v", err)
+		return
 	}
-	config.PxL = string(content)
+	*secret = value
+}
 
-	return config, nil
+// resolveHeaderFiles reads the header values named by files into headers.
+func resolveHeaderFiles(p *problems, key string, headers *map[string]Secret, files map[string]string) {
+	for name, path := range files {
+		if _, set := (*headers)[name]; set {
+			p.add(key+".header_files", "header %s is also set in %s.headers (%s)", name, key, p.origins.of(key+".headers"))
+			continue
+		}
+		value, err := readSecretFile(path)
+		if err != nil {
+			p.add(key+".header_files", "header %s: %v", name, err)
+			continue
+		}
+		if *headers == nil {
+			*headers = map[string]Secret{}
+		}
+		(*headers)[name] = value
+	}
+}
+
+// readScripts reads the PxL of every script given by path.
+func readScripts(config *Config) error {
+	for i := range config.Scripts {
+		script := &config.Scripts[i]
+		if script.PxL != "" {
+			continue
+		}
+
+		content, err := os.ReadFile(script.Path)
+		if err != nil {
+			return fmt.Errorf("scripts[%d].path (%s): %w", i, config.Origin(fmt.Sprintf("scripts[%d].path", i)), err)
+		}
+		script.PxL = string(content)
+		script.loaded = true
+	}
+	return nil
 }
@@ -0,0 +1,316 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolateEnv clears every environment variable the configuration reads, so
+// that the environment of the test run does not leak into the configuration.
+func isolateEnv(t *testing.T) {
+	t.Helper()
+	clear := func(name string) {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	clear("OBSERVER_CONFIG")
+	for _, s := range settings {
+		if s.env == "" {
+			continue
+		}
+		clear(s.env)
+		if s.secret {
+			clear(s.env + "_FILE")
+		}
+	}
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	script := writeFile(t, "script.pxl", "import px\n")
+	file := writeFile(t, "observer.yaml", `
+gateway:
+  url: file:443
+execution:
+  interval: 20s
+  idle_timeout: 2m
+`)
+
+	tests := []struct {
+		name        string
+		file        bool
+		env         map[string]string
+		args        []string
+		url         string
+		interval    time.Duration
+		idleTimeout time.Duration
+	}{
+		{
+			name:        "defaults",
+			env:         map[string]string{"ORBSERVABILITY_URL": "env:443"},
+			url:         "env:443",
+			interval:    10 * time.Second,
+			idleTimeout: time.Minute,
+		},
+		{
+			name:        "file over defaults",
+			file:        true,
+			url:         "file:443",
+			interval:    20 * time.Second,
+			idleTimeout: 2 * time.Minute,
+		},
+		{
+			name:        "environment over file",
+			file:        true,
+			env:         map[string]string{"PIXIE_STREAM_IDLE_TIMEOUT": "3m"},
+			url:         "file:443",
+			interval:    20 * time.Second,
+			idleTimeout: 3 * time.Minute,
+		},
+		{
+			name:        "flags over environment",
+			file:        true,
+			env:         map[string]string{"ORBSERVABILITY_URL": "env:443", "PIXIE_STREAM_IDLE_TIMEOUT": "3m"},
+			args:        []string{"-idle-timeout", "4m"},
+			url:         "env:443",
+			interval:    20 * time.Second,
+			idleTimeout: 4 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateEnv(t)
+			t.Setenv("PXL_FILE_PATH", script)
+			if tt.file {
+				t.Setenv("OBSERVER_CONFIG", file)
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := Load(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Gateway.URL != tt.url {
+				t.Errorf("gateway.url = %q, want %q", cfg.Gateway.URL, tt.url)
+			}
+			if got := cfg.Execution.Interval.Duration(); got != tt.interval {
+				t.Errorf("execution.interval = %s, want %s", got, tt.interval)
+			}
+			if got := cfg.Execution.IdleTimeout.Duration(); got != tt.idleTimeout {
+				t.Errorf("execution.idle_timeout = %s, want %s", got, tt.idleTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadAppliesListsBeforeFields(t *testing.T) {
+	isolateEnv(t)
+	t.Setenv("PXL_FILE_PATH", writeFile(t, "script.pxl", "import px\n"))
+	t.Setenv("ORBSERVABILITY_URL", "gateway:443")
+	t.Setenv("VIZIER_HOST", "vizier")
+
+	cfg, err := Load([]string{"-pixie-url", "pem:12345"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Sources) != 1 || cfg.Sources[0].URL != "pem:12345" || cfg.Sources[0].VizierHost != "vizier" {
+		t.Fatalf("sources = %+v, want pem:12345 with vizier host from the environment", cfg.Sources)
+	}
+}
+
+func TestLoadReportsOrigin(t *testing.T) {
+	isolateEnv(t)
+	t.Setenv("PXL_FILE_PATH", writeFile(t, "script.pxl", "import px\n"))
+	t.Setenv("ORBSERVABILITY_URL", "gateway:443")
+	t.Setenv("PIXIE_STREAM_IDLE_TIMEOUT", "soon")
+
+	_, err := Load(nil)
+	if err == nil || !strings.Contains(err.Error(), "env PIXIE_STREAM_IDLE_TIMEOUT") {
+		t.Fatalf("Load returned %v, want a problem naming env PIXIE_STREAM_IDLE_TIMEOUT", err)
+	}
+}
+
+func TestLoadRejectsSampleProcessorWithoutRate(t *testing.T) {
+	isolateEnv(t)
+	t.Setenv("PXL_FILE_PATH", writeFile(t, "script.pxl", "import px\n"))
+	t.Setenv("OBSERVER_CONFIG", writeFile(t, "observer.yaml", `
+gateway:
+  url: gateway:443
+processors:
+  - type: sample
+`))
+
+	_, err := Load(nil)
+	if err == nil || !strings.Contains(err.Error(), "processors[0].rate") {
+		t.Fatalf("Load returned %v, want a problem with processors[0].rate", err)
+	}
+}
+
+func TestLoadWarnsAboutTLSFilesWhileDisabled(t *testing.T) {
+	isolateEnv(t)
+	t.Setenv("PXL_FILE_PATH", writeFile(t, "script.pxl", "import px\n"))
+	t.Setenv("OBSERVER_CONFIG", writeFile(t, "observer.yaml", `
+gateway:
+  url: gateway:443
+  tls:
+    cert_file: /missing/cert.pem
+    key_file: /missing/key.pem
+`))
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Warnings) != 2 || cfg.Warnings[0].Key != "gateway.tls.cert_file" || cfg.Warnings[1].Key != "gateway.tls.key_file" {
+		t.Fatalf("warnings = %v, want one for gateway.tls.cert_file and gateway.tls.key_file", cfg.Warnings)
+	}
+}
+
+func TestLoadRejectsNonPositiveInterval(t *testing.T) {
+	isolateEnv(t)
+	t.Setenv("PXL_FILE_PATH", writeFile(t, "script.pxl", "import px\n"))
+	t.Setenv("ORBSERVABILITY_URL", "gateway:443")
+
+	_, err := Load([]string{"-interval", "0s"})
+	if err == nil || !strings.Contains(err.Error(), "execution.interval") {
+		t.Fatalf("Load returned %v, want a problem with execution.interval", err)
+	}
+}
+
+func TestLoadLegacyStreamSleep(t *testing.T) {
+	script := writeFile(t, "script.pxl", "import px\n")
+	file := writeFile(t, "observer.yaml", `
+gateway:
+  url: gateway:443
+execution:
+  stream_sleep: 15
+`)
+
+	tests := []struct {
+		name     string
+		file     bool
+		env      string
+		args     []string
+		interval time.Duration
+		warning  string
+	}{
+		{name: "file key", file: true, interval: 15 * time.Second, warning: "execution.stream_sleep is deprecated"},
+		{name: "flag", args: []string{"-stream-sleep", "20"}, interval: 20 * time.Second, warning: "-stream-sleep is deprecated"},
+		{name: "environment", env: "30", interval: 30 * time.Second},
+		{name: "environment over file key", file: true, env: "5s", interval: 5 * time.Second, warning: "execution.stream_sleep is deprecated"},
+		{name: "interval over deprecated flag", args: []string{"-stream-sleep", "20", "-interval", "1m"}, interval: time.Minute, warning: "-stream-sleep is deprecated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateEnv(t)
+			t.Setenv("PXL_FILE_PATH", script)
+			t.Setenv("ORBSERVABILITY_URL", "gateway:443")
+			if tt.file {
+				t.Setenv("OBSERVER_CONFIG", file)
+			}
+			if tt.env != "" {
+				t.Setenv("PIXIE_STREAM_SLEEP", tt.env)
+			}
+
+			cfg, err := Load(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.Execution.Interval.Duration(); got != tt.interval {
+				t.Errorf("execution.interval = %s, want %s", got, tt.interval)
+			}
+			var warnings []string
+			for _, w := range cfg.Warnings {
+				warnings = append(warnings, w.Message)
+			}
+			if tt.warning == "" && len(warnings) > 0 || tt.warning != "" && (len(warnings) != 1 || !strings.Contains(warnings[0], tt.warning)) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.warning)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsBareIntegerDurations(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		key  string
+	}{
+		{name: "flag", args: []string{"-interval", "10"}, key: "execution.interval (flag -interval)"},
+		{name: "environment", env: map[string]string{"PIXIE_STREAM_IDLE_TIMEOUT": "60"}, key: "execution.idle_timeout (env PIXIE_STREAM_IDLE_TIMEOUT)"},
+		{name: "file", file: "execution:\n  interval: 10\n", key: `line 2: "10" is not a duration`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateEnv(t)
+			t.Setenv("PXL_FILE_PATH", writeFile(t, "script.pxl", "import px\n"))
+			t.Setenv("ORBSERVABILITY_URL", "gateway:443")
+			if tt.file != "" {
+				t.Setenv("OBSERVER_CONFIG", writeFile(t, "observer.yaml", tt.file))
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := Load(tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.key) {
+				t.Fatalf("Load returned %v, want an error about %s", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsRemoteSubscriptionsAddr(t *testing.T) {
+	tests := []struct {
+		addr        string
+		allowRemote bool
+		rejected    bool
+	}{
+		{addr: "unix:/run/observer/subscriptions.sock"},
+		{addr: "localhost:4320"},
+		{addr: "127.0.0.1:4320"},
+		{addr: "[::1]:4320"},
+		{addr: ":4320", rejected: true},
+		{addr: "0.0.0.0:4320", rejected: true},
+		{addr: "10.0.0.7:4320", rejected: true},
+		{addr: "observer.example.com:4320", rejected: true},
+		{addr: ":4320", allowRemote: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s allow_remote=%t", tt.addr, tt.allowRemote), func(t *testing.T) {
+			isolateEnv(t)
+			t.Setenv("PXL_FILE_PATH", writeFile(t, "script.pxl", "import px\n"))
+			t.Setenv("OBSERVER_CONFIG", writeFile(t, "observer.yaml", fmt.Sprintf(`
+sinks:
+  - type: subscriptions
+    subscriptions:
+      addr: %q
+      allow_remote: %t
+`, tt.addr, tt.allowRemote)))
+
+			_, err := Load(nil)
+			if tt.rejected && (err == nil || !strings.Contains(err.Error(), "sinks[0].subscriptions.addr")) {
+				t.Fatalf("Load returned %v, want a problem with sinks[0].subscriptions.addr", err)
+			}
+			if !tt.rejected && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Change is a setting that differs between two configurations.
+// Secrets are redacted in Old and New.
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Key, c.Old, c.New)
+}
+
+// Diff lists the settings that differ between old and new, keyed by their file key path.
+func Diff(old *Config, new *Config) []Change {
+	var changes []Change
+	diffValue(&changes, "", reflect.ValueOf(*old), reflect.ValueOf(*new))
+	return changes
+}
+
+// ChangedUnder reports whether any change is at or below one of the key prefixes.
+func ChangedUnder(changes []Change, prefixes ...string) bool {
+	for _, change := range changes {
+		for _, prefix := range prefixes {
+			if change.Key == prefix || strings.HasPrefix(change.Key, prefix+".") || strings.HasPrefix(change.Key, prefix+"[") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func diffValue(changes *[]Change, key string, old reflect.Value, new reflect.Value) {
+	switch old.Kind() {
+	case reflect.Struct:
+		t := old.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if !field.IsExported() || name == "-" || name == "" {
+				continue
+			}
+			if key != "" {
+				name = key + "." + name
+			}
+			diffValue(changes, name, old.Field(i), new.Field(i))
+		}
+	case reflect.Slice:
+		if old.Type().Elem().Kind() != reflect.Struct {
+			if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+				*changes = append(*changes, Change{Key: key, Old: formatValue(old), New: formatValue(new)})
+			}
+			return
+		}
+		for i := 0; i < old.Len() || i < new.Len(); i++ {
+			itemKey := fmt.Sprintf("%s[%d]", key, i)
+			switch {
+			case i >= new.Len():
+				*changes = append(*changes, Change{Key: itemKey, Old: "present", New: "removed"})
+			case i >= old.Len():
+				*changes = append(*changes, Change{Key: itemKey, Old: "absent", New: "added"})
+			default:
+				diffValue(changes, itemKey, old.Index(i), new.Index(i))
+			}
+		}
+	case reflect.Map:
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			*changes = append(*changes, Change{Key: key, Old: formatValue(old), New: formatValue(new)})
+		}
+	default:
+		if old.Interface() != new.Interface() {
+			*changes = append(*changes, Change{Key: key, Old: formatValue(old), New: formatValue(new)})
+		}
+	}
+}
+
+// formatValue renders a setting for a diff, summarising long values such as inline scripts.
+func formatValue(v reflect.Value) string {
+	s := fmt.Sprint(v.Interface()) // Secrets redact themselves
+	if len(s) > 64 {
+		return fmt.Sprintf("<%d bytes>", len(s))
+	}
+	if s == "" {
+		return `""`
+	}
+	return s
+}
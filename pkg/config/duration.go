@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration written as a Go duration string such as "500ms"
+// or "2m".
+type Duration time.Duration
+
+// Duration returns d as a time.Duration.
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expected a duration such as \"500ms\" or \"2m\"", node.Line)
+	}
+	val, err := parseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*d = val
+	return nil
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
+
+// parseSeconds parses a duration or, as the settings from before durations
+// were supported did (e.g. PIXIE_STREAM_SLEEP=10), a bare integer of seconds.
+func parseSeconds(value string) (Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return Duration(time.Duration(seconds) * time.Second), nil
+	}
+	return parseDuration(value)
+}
+
+func parseDuration(value string) (Duration, error) {
+	val, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration such as \"500ms\" or \"2m\"", value)
+	}
+	return Duration(val), nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile decodes the YAML file at path on top of config and records every
+// key it sets. Unknown keys are rejected so that typos do not go unnoticed.
+func loadFile(path string, config *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+	config.File = path
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+	if len(root.Content) > 0 {
+		recordKeys(config.origins, "", root.Content[0], fileOrigin(path))
+	}
+
+	return nil
+}
+
+// recordKeys records origin for every key path below node.
+func recordKeys(o origins, prefix string, node *yaml.Node, origin string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			o.set(key, origin)
+			recordKeys(o, key, node.Content[i+1], origin)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			key := fmt.Sprintf("%s[%d]", prefix, i)
+			o.set(key, origin)
+			recordKeys(o, key, item, origin)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// IndexName returns the index of an event at t, replacing the time layout in
+// braces, if any, by the UTC date.
+func (o OpenSearchSinkConfig) IndexName(t time.Time) string {
+	prefix, rest, found := strings.Cut(o.Index, "{")
+	if !found {
+		return o.Index
+	}
+	layout, suffix, _ := strings.Cut(rest, "}")
+	return prefix + t.UTC().Format(layout) + suffix
+}
+
+// IndexPattern returns a wildcard pattern matching every index named by IndexName.
+func (o OpenSearchSinkConfig) IndexPattern() string {
+	prefix, rest, found := strings.Cut(o.Index, "{")
+	if !found {
+		return o.Index
+	}
+	_, suffix, _ := strings.Cut(rest, "}")
+	return prefix + "*" + suffix
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// origins records where each setting was last set, keyed by its file key
+// path such as "sources[1].tls.ca_file". Settings without an entry, or under
+// a parent without one, come from the defaults.
+type origins map[string]string
+
+func envOrigin(name string) string  { return "env " + name }
+func flagOrigin(name string) string { return "flag -" + name }
+func fileOrigin(path string) string { return "file " + path }
+
+const defaultOrigin = "default"
+
+// set records the origin of key, replacing the origins of anything below it.
+func (o origins) set(key string, origin string) {
+	for k := range o {
+		if strings.HasPrefix(k, key+".") || strings.HasPrefix(k, key+"[") {
+			delete(o, k)
+		}
+	}
+	o[key] = origin
+}
+
+// setAll records origin for key, expanding "sources[*]" to every configured source.
+func (o origins) setAll(config *Config, key string, origin string) {
+	prefix, suffix, found := strings.Cut(key, "[*]")
+	if !found {
+		o.set(key, origin)
+		return
+	}
+	for i := range config.Sources {
+		o.set(fmt.Sprintf("%s[%d]%s", prefix, i, suffix), origin)
+	}
+}
+
+// of returns the origin of key, falling back to the closest parent key.
+func (o origins) of(key string) string {
+	for {
+		if origin, found := o[key]; found {
+			return origin
+		}
+		i := strings.LastIndexAny(key, ".[")
+		if i < 0 {
+			return defaultOrigin
+		}
+		key = key[:i]
+	}
+}
+
+// Origin describes where the setting at key came from, e.g. "env PIXIE_URL".
+func (c *Config) Origin(key string) string {
+	return c.origins.of(key)
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Remote is the part of the configuration managed by the event gateway.
+type Remote struct {
+	Version    string // Opaque version chosen by the gateway
+	Scripts    []Script
+	Processors []ProcessorConfig
+}
+
+func remoteOrigin(version string) string { return "remote version " + version }
+
+// WithRemote returns a copy of c with its scripts and processors replaced by
+// those in remote, or a *ValidationError if the result is invalid.
+// An empty remote script set keeps the local scripts.
+func (c *Config) WithRemote(remote Remote) (*Config, error) {
+	config := *c
+	config.origins = maps.Clone(c.origins)
+	p := &problems{origins: config.origins}
+
+	origin := remoteOrigin(remote.Version)
+	if len(remote.Scripts) > 0 {
+		config.Scripts = remote.Scripts
+		config.origins.set("scripts", origin)
+		for i, script := range config.Scripts {
+			if script.Name == "" {
+				p.add(fmt.Sprintf("scripts[%d].name", i), "required")
+			}
+		}
+	}
+	config.Processors = remote.Processors
+	config.origins.set("processors", origin)
+
+	config.validate(p)
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
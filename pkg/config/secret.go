@@ -0,0 +1,52 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Secret holds a sensitive value such as an API key. It prints, logs and
+// marshals as "[REDACTED]"; use Value to get the raw value where it is sent.
+type Secret string
+
+// Value returns the raw secret.
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return fmt.Sprintf("config.Secret(%q)", s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// readSecretFile reads a secret mounted as a file, dropping the trailing
+// newline most secret stores add.
+func readSecretFile(path string) (Secret, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %w", path, unwrapPathError(err))
+	}
+	return Secret(strings.TrimRight(string(content), "\r\n")), nil
+}
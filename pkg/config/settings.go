@@ -0,0 +1,303 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	phaseList  = iota // Settings that replace a whole list
+	phaseField        // Settings that adjust fields, including every entry of a list
+)
+
+// setting is a configuration value that can be given as an environment
+// variable and as a command line flag.
+type setting struct {
+	key        string // File key path, "sources[*]" applies to every source
+	env        string // Empty when the setting has no environment variable
+	flag       string // Empty when the setting has no flag
+	usage      string
+	phase      int
+	allowEmpty bool   // Whether an empty environment variable is applied
+	secret     bool   // Also read from the file named by env+"_FILE", and never from a flag
+	deprecated string // Flag replacing a deprecated flag
+	apply      func(config *Config, value string) error
+	applyEnv   func(config *Config, value string) error // Replaces apply for env, to accept a legacy format
+}
+
+var settings = []setting{
+	{env: "PIXIE_URL", key: "sources", flag: "pixie-url", usage: "address of a single Pixie PEM", phase: phaseList, apply: func(c *Config, v string) error {
+		c.Sources = []PixieSource{{Name: v, URL: v}}
+		return nil
+	}},
+	{env: "PIXIE_SOURCES", key: "sources", flag: "pixie-sources", usage: "comma separated name=host:port Pixie sources", phase: phaseList, apply: func(c *Config, v string) error {
+		sources, err := parseSources(v)
+		if err != nil {
+			return err
+		}
+		c.Sources = sources
+		return nil
+	}},
+	{env: "PXL_FILE_PATH", key: "scripts", flag: "pxl-file", usage: "path of a single PxL script", phase: phaseList, apply: func(c *Config, v string) error {
+		c.Scripts = []Script{{Path: v}}
+		return nil
+	}},
+
+	{env: "ORBSERVABILITY_URL", key: "gateway.url", flag: "orbservability-url", usage: "address of the event gateway", phase: phaseField, apply: func(c *Config, v string) error {
+		c.Gateway.URL = v
+		return nil
+	}},
+	{env: "ORBSERVABILITY_API_KEY", key: "gateway.api_key", phase: phaseField, secret: true, apply: func(c *Config, v string) error {
+		c.Gateway.APIKey = Secret(v)
+		c.Gateway.APIKeyFile = ""
+		return nil
+	}},
+	{env: "ORBSERVABILITY_TLS", key: "gateway.tls.enabled", phase: phaseField, apply: func(c *Config, v string) error {
+		return parseBool(v, &c.Gateway.TLS.Enabled)
+	}},
+	{env: "ORBSERVABILITY_TLS_CA_FILE", key: "gateway.tls.ca_file", phase: phaseField, apply: func(c *Config, v string) error {
+		c.Gateway.TLS.CAFile = v
+		return nil
+	}},
+	{env: "ORBSERVABILITY_TLS_CERT_FILE", key: "gateway.tls.cert_file", phase: phaseField, apply: func(c *Config, v string) error {
+		c.Gateway.TLS.CertFile = v
+		return nil
+	}},
+	{env: "ORBSERVABILITY_TLS_KEY_FILE", key: "gateway.tls.key_file", phase: phaseField, apply: func(c *Config, v string) error {
+		c.Gateway.TLS.KeyFile = v
+		return nil
+	}},
+	{env: "ORBSERVABILITY_TLS_SERVER_NAME", key: "gateway.tls.server_name", phase: phaseField, apply: func(c *Config, v string) error {
+		c.Gateway.TLS.ServerName = v
+		return nil
+	}},
+	{env: "VIZIER_HOST", key: "sources[*].vizier_host", flag: "vizier-host", usage: "Vizier host of every Pixie source", phase: phaseField, apply: func(c *Config, v string) error {
+		forEachSource(c, func(s *PixieSource) { s.VizierHost = v })
+		return nil
+	}},
+	{env: "PIXIE_TLS", key: "sources[*].tls.enabled", phase: phaseField, apply: func(c *Config, v string) error {
+		var enabled bool
+		if err := parseBool(v, &enabled); err != nil {
+			return err
+		}
+		forEachSource(c, func(s *PixieSource) { s.TLS.Enabled = enabled })
+		return nil
+	}},
+	{env: "PIXIE_TLS_CA_FILE", key: "sources[*].tls.ca_file", phase: phaseField, apply: func(c *Config, v string) error {
+		forEachSource(c, func(s *PixieSource) { s.TLS.CAFile = v })
+		return nil
+	}},
+	{env: "PIXIE_TLS_CERT_FILE", key: "sources[*].tls.cert_file", phase: phaseField, apply: func(c *Config, v string) error {
+		forEachSource(c, func(s *PixieSource) { s.TLS.CertFile = v })
+		return nil
+	}},
+	{env: "PIXIE_TLS_KEY_FILE", key: "sources[*].tls.key_file", phase: phaseField, apply: func(c *Config, v string) error {
+		forEachSource(c, func(s *PixieSource) { s.TLS.KeyFile = v })
+		return nil
+	}},
+	{env: "PIXIE_TLS_SERVER_NAME", key: "sources[*].tls.server_name", phase: phaseField, apply: func(c *Config, v string) error {
+		forEachSource(c, func(s *PixieSource) { s.TLS.ServerName = v })
+		return nil
+	}},
+	{env: "ORBSERVABILITY_SEND_TIMEOUT", key: "gateway.send_timeout", flag: "send-timeout", usage: "longest a send to the gateway may block, 0 disables", phase: phaseField, apply: func(c *Config, v string) error {
+		return parseDurationInto(v, &c.Gateway.SendTimeout)
+	}},
+	{key: "execution.interval", flag: "stream-sleep", usage: "deprecated, use -interval", deprecated: "interval", phase: phaseField, apply: func(c *Config, v string) error {
+		return parseSecondsInto(v, &c.Execution.Interval)
+	}},
+	{env: "PIXIE_STREAM_SLEEP", key: "execution.interval", flag: "interval", usage: "delay between script executions", phase: phaseField, apply: func(c *Config, v string) error {
+		return parseDurationInto(v, &c.Execution.Interval)
+	}, applyEnv: func(c *Config, v string) error {
+		return parseSecondsInto(v, &c.Execution.Interval)
+	}},
+	{env: "PIXIE_STREAM_IDLE_TIMEOUT", key: "execution.idle_timeout", flag: "idle-timeout", usage: "minimum time without data before a stream is re-executed, raised for scripts whose data is sparser, 0 disables", phase: phaseField, apply: func(c *Config, v string) error {
+		return parseDurationInto(v, &c.Execution.IdleTimeout)
+	}},
+	{env: "PIXIE_BACKOFF_BASE", key: "execution.backoff.base", flag: "backoff-base", usage: "delay before the first retry of a failed execution", phase: phaseField, apply: func(c *Config, v string) error {
+		return parseDurationInto(v, &c.Execution.Backoff.Base)
+	}},
+	{env: "PIXIE_BACKOFF_MAX", key: "execution.backoff.max", flag: "backoff-max", usage: "longest delay between retries of a failed execution", phase: phaseField, apply: func(c *Config, v string) error {
+		return parseDurationInto(v, &c.Execution.Backoff.Max)
+	}},
+	{env: "PIXIE_ERROR_MAX", key: "execution.max_error_count", flag: "max-errors", usage: "failed executions tolerated before a source is restarted", phase: phaseField, apply: func(c *Config, v string) error {
+		return parseInt(v, &c.Execution.MaxErrorCount)
+	}},
+	{env: "QUEUE_SIZE", key: "queue.size", flag: "queue-size", usage: "events buffered for each sink", phase: phaseField, apply: func(c *Config, v string) error {
+		return parseInt(v, &c.Queue.Size)
+	}},
+	{env: "QUEUE_OVERFLOW", key: "queue.overflow", flag: "queue-overflow", usage: `"block" or "drop" when the queue is full`, phase: phaseField, apply: func(c *Config, v string) error {
+		c.Queue.Overflow = v
+		return nil
+	}},
+	{env: "QUEUE_DRAIN_DEADLINE", key: "queue.drain_deadline", flag: "drain-deadline", usage: "time allowed to send queued events on shutdown", phase: phaseField, apply: func(c *Config, v string) error {
+		return parseDurationInto(v, &c.Queue.DrainDeadline)
+	}},
+	{env: "OBSERVER_REMOTE_CONFIG", key: "remote.enabled", flag: "remote-config", usage: "fetch scripts and processors from the event gateway", phase: phaseField, apply: func(c *Config, v string) error {
+		return parseBool(v, &c.Remote.Enabled)
+	}},
+	{env: "OBSERVER_ID", key: "remote.observer_id", flag: "observer-id", usage: "identity of the observer at the event gateway", phase: phaseField, apply: func(c *Config, v string) error {
+		c.Remote.ObserverID = v
+		return nil
+	}},
+	{env: "QUEUE_FLUSH_INTERVAL", key: "queue.flush_interval", flag: "flush-interval", usage: "longest an event may be buffered by a sink", phase: phaseField, apply: func(c *Config, v string) error {
+		return parseDurationInto(v, &c.Queue.FlushInterval)
+	}},
+	{env: "METRICS_ADDR", key: "metrics.addr", flag: "metrics-addr", usage: "metrics listen address, empty disables", phase: phaseField, allowEmpty: true, apply: func(c *Config, v string) error {
+		c.Metrics.Addr = v
+		return nil
+	}},
+}
+
+// applyEnv applies the environment variables of the given phase, adding a
+// problem for every value that cannot be parsed.
+func applyEnv(config *Config, phase int, p *problems) {
+	for _, s := range settings {
+		if s.phase != phase || s.env == "" {
+			continue
+		}
+		if s.applyEnv != nil {
+			s.apply = s.applyEnv
+		}
+		if s.secret {
+			if path := os.Getenv(s.env + "_FILE"); path != "" {
+				value, err := readSecretFile(path)
+				if err != nil {
+					p.addFrom(s.key, envOrigin(s.env+"_FILE"), "%v", err)
+					continue
+				}
+				s.applyFrom(config, value.Value(), envOrigin(s.env+"_FILE"), p)
+				continue
+			}
+		}
+
+		value, found := os.LookupEnv(s.env)
+		if !found || (value == "" && !s.allowEmpty) {
+			continue
+		}
+		s.applyFrom(config, value, envOrigin(s.env), p)
+	}
+}
+
+func (s setting) applyFrom(config *Config, value string, origin string, p *problems) {
+	if err := s.apply(config, value); err != nil {
+		p.addFrom(s.key, origin, "%v", err)
+		return
+	}
+	config.origins.setAll(config, s.key, origin)
+}
+
+// flagValues holds the command line flags registered for settings.
+type flagValues struct {
+	fs     *flag.FlagSet
+	values map[string]*string
+}
+
+func registerFlags(fs *flag.FlagSet) *flagValues {
+	f := &flagValues{fs: fs, values: map[string]*string{}}
+	for _, s := range settings {
+		if s.flag == "" || s.secret {
+			continue // Flags are visible in the process list
+		}
+		usage := s.usage
+		if s.env != "" {
+			usage = fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		}
+		f.values[s.flag] = fs.String(s.flag, "", usage)
+	}
+	return f
+}
+
+// apply applies the flags of the given phase that were set explicitly.
+func (f *flagValues) apply(config *Config, phase int, p *problems) {
+	set := map[string]bool{}
+	f.fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+
+	for _, s := range settings {
+		if s.phase != phase || !set[s.flag] {
+			continue
+		}
+		s.applyFrom(config, *f.values[s.flag], flagOrigin(s.flag), p)
+		if s.deprecated != "" {
+			p.warn(s.key, "-%s is deprecated, use -%s", s.flag, s.deprecated)
+		}
+	}
+}
+
+func forEachSource(config *Config, fn func(*PixieSource)) {
+	for i := range config.Sources {
+		fn(&config.Sources[i])
+	}
+}
+
+func parseInt(value string, dst *int) error {
+	val, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", value)
+	}
+	*dst = val
+	return nil
+}
+
+func parseSecondsInto(value string, dst *Duration) error {
+	val, err := parseSeconds(value)
+	if err != nil {
+		return err
+	}
+	*dst = val
+	return nil
+}
+
+func parseDurationInto(value string, dst *Duration) error {
+	val, err := parseDuration(value)
+	if err != nil {
+		return err
+	}
+	*dst = val
+	return nil
+}
+
+func parseBool(value string, dst *bool) error {
+	val, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", value)
+	}
+	*dst = val
+	return nil
+}
+
+// parseSources parses a comma separated list of sources, each either
+// "name=host:port" or a bare "host:port" which is also used as the name.
+func parseSources(value string) ([]PixieSource, error) {
+	var sources []PixieSource
+	names := map[string]bool{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, url, found := strings.Cut(entry, "=")
+		if !found {
+			url = name
+		}
+		name, url = strings.TrimSpace(name), strings.TrimSpace(url)
+		if name == "" || url == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected name=host:port", entry)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate source name %q", name)
+		}
+		names[name] = true
+
+		sources = append(sources, PixieSource{Name: name, URL: url})
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no sources listed")
+	}
+	return sources, nil
+}
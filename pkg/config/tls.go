@@ -0,0 +1,71 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+)
+
+// ClientConfig builds a *tls.Config for connecting to the server at addr.
+// The CA bundle replaces the system roots when set, the client certificate is
+// presented when both the certificate and key are set, and the server name
+// defaults to the host part of addr.
+func (cfg TLSConfig) ClientConfig(addr string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2"}, // gRPC requires HTTP/2
+		ServerName: cfg.ServerName,
+	}
+
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+		}
+		tlsConfig.ServerName = host
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// HTTPClientConfig builds a *tls.Config for requests to an https URL, leaving
+// the HTTP version to be negotiated by the transport.
+func (cfg TLSConfig) HTTPClientConfig(rawURL string) (*tls.Config, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	tlsConfig, err := cfg.ClientConfig(net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.NextProtos = nil
+	return tlsConfig, nil
+}
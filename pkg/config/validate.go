@@ -0,0 +1,635 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"orbservability/observer/pkg/event"
+)
+
+// Problem is a single invalid setting.
+type Problem struct {
+	Key     string // File key path of the setting, e.g. "sources[0].url"
+	Origin  string // Where the value came from, e.g. "env PIXIE_URL"
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s (%s): %s", p.Key, p.Origin, p.Message)
+}
+
+// ValidationError reports every problem found while loading a configuration.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration, %d problem(s):", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(p.String())
+	}
+	return b.String()
+}
+
+// problems collects Problems, looking up the origin of each key.
+type problems struct {
+	origins  origins
+	list     []Problem
+	warnings []Problem // Do not make the configuration invalid
+}
+
+func (p *problems) add(key string, format string, args ...any) {
+	p.addFrom(key, p.origins.of(key), format, args...)
+}
+
+func (p *problems) addFrom(key string, origin string, format string, args ...any) {
+	p.list = append(p.list, Problem{Key: key, Origin: origin, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *problems) warn(key string, format string, args ...any) {
+	p.warnings = append(p.warnings, Problem{Key: key, Origin: p.origins.of(key), Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *problems) err() error {
+	if len(p.list) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: p.list}
+}
+
+// Validate checks every setting of a resolved configuration and returns a
+// *ValidationError listing all problems, or nil. Warnings are recorded in
+// c.Warnings.
+func (c *Config) Validate() error {
+	p := &problems{origins: c.origins}
+	c.validate(p)
+	c.Warnings = p.warnings
+	return p.err()
+}
+
+func (c *Config) validate(p *problems) {
+	switch {
+	case c.Gateway.URL != "":
+		validateTarget(p, "gateway.url", c.Gateway.URL)
+	case c.usesGateway():
+		p.add("gateway.url", "required, set ORBSERVABILITY_URL, -orbservability-url or gateway.url")
+	}
+	validateTLS(p, "gateway.tls", c.Gateway.TLS)
+
+	if len(c.Sources) == 0 {
+		p.add("sources", "at least one source is required")
+	}
+	names := map[string]bool{}
+	for i, source := range c.Sources {
+		key := fmt.Sprintf("sources[%d]", i)
+		if names[source.Name] {
+			p.add(key+".name", "duplicate source name %q", source.Name)
+		}
+		names[source.Name] = true
+		if source.URL == "" {
+			p.add(key+".url", "required")
+		} else {
+			validateHostPort(p, key+".url", source.URL)
+		}
+		if source.VizierHost == "" {
+			p.add(key+".vizier_host", "required")
+		}
+		validateTLS(p, key+".tls", source.TLS)
+	}
+
+	if len(c.Scripts) == 0 {
+		p.add("scripts", "at least one script is required")
+	}
+	names = map[string]bool{}
+	for i, script := range c.Scripts {
+		key := fmt.Sprintf("scripts[%d]", i)
+		if names[script.Name] {
+			p.add(key+".name", "duplicate script name %q", script.Name)
+		}
+		names[script.Name] = true
+		switch {
+		case script.Path != "" && script.PxL != "" && !script.loaded:
+			p.add(key, "path and pxl are mutually exclusive")
+		case script.Path == "" && script.PxL == "":
+			p.add(key, "one of path or pxl is required")
+		case script.Path != "":
+			validateReadable(p, key+".path", script.Path)
+		}
+	}
+
+	validateNonNegative(p, "gateway.send_timeout", c.Gateway.SendTimeout)
+	if c.Execution.Interval <= 0 {
+		p.add("execution.interval", "must be positive, got %s", c.Execution.Interval)
+	}
+	validateNonNegative(p, "execution.idle_timeout", c.Execution.IdleTimeout)
+	validateBackoff(p, "execution.backoff", c.Execution.Backoff)
+	if c.Execution.MaxErrorCount < 0 {
+		p.add("execution.max_error_count", "must not be negative, got %d", c.Execution.MaxErrorCount)
+	}
+
+	validateQueue(p, "queue", c.Queue, nil)
+
+	if len(c.Sinks) == 0 {
+		p.add("sinks", "at least one sink is required")
+	}
+	names = map[string]bool{}
+	metricsSinks := 0
+	for i, sink := range c.Sinks {
+		key := fmt.Sprintf("sinks[%d]", i)
+		if names[sink.Name] {
+			p.add(key+".name", "duplicate sink name %q", sink.Name)
+		}
+		names[sink.Name] = true
+		switch sink.Type {
+		case SinkGateway:
+		case SinkFile:
+			validateFileSink(p, key+".file", sink.File)
+		case SinkStdout:
+			validateStdoutSink(p, key+".stdout", sink.Stdout)
+		case SinkTraces, SinkLogs:
+			validateOTLPSink(p, key+".otlp", sink.OTLP)
+		case SinkWebhook:
+			validateWebhookSink(p, key+".webhook", sink.Webhook)
+		case SinkLoki:
+			validateLokiSink(p, key+".loki", sink.Loki)
+		case SinkSearch:
+			validateOpenSearchSink(p, key+".opensearch", sink.OpenSearch)
+		case SinkParquet:
+			validateParquetSink(p, key+".parquet", sink.Parquet)
+		case SinkKafka:
+			validateKafkaSink(p, key+".kafka", sink.Kafka)
+		case SinkServer:
+			validateSubscriptionsSink(p, key+".subscriptions", sink.Subscriptions)
+		case SinkMetrics:
+			if metricsSinks++; metricsSinks > 1 {
+				p.add(key+".type", "only one %s sink may be configured", SinkMetrics)
+			}
+			if c.Metrics.Addr == "" {
+				p.add(key+".type", "%s sink requires metrics.addr, set METRICS_ADDR, -metrics-addr or metrics.addr", SinkMetrics)
+			}
+			validateMetricsSink(p, key+".metrics", sink.Metrics)
+		default:
+			p.add(key+".type", "must be one of %s, got %q", strings.Join(sinkTypes, ", "), sink.Type)
+		}
+		if sink.OnError != OnErrorFail && sink.OnError != OnErrorDrop {
+			p.add(key+".on_error", "must be %q or %q, got %q", OnErrorFail, OnErrorDrop, sink.OnError)
+		}
+		validateQueue(p, key+".queue", sink.Queue, &c.Queue)
+	}
+
+	for i, processor := range c.Processors {
+		validateProcessor(p, fmt.Sprintf("processors[%d]", i), processor)
+	}
+
+	if c.Metrics.Addr != "" {
+		validateHostPort(p, "metrics.addr", c.Metrics.Addr)
+	}
+
+	if c.Remote.Enabled {
+		if c.Remote.ObserverID == "" {
+			p.add("remote.observer_id", "required when remote.enabled is set, set OBSERVER_ID, -observer-id or remote.observer_id")
+		}
+		validateBackoff(p, "remote.backoff", c.Remote.Backoff)
+	}
+}
+
+var sinkTypes = []string{SinkGateway, SinkFile, SinkStdout, SinkTraces, SinkLogs, SinkMetrics, SinkWebhook, SinkLoki, SinkSearch, SinkParquet, SinkKafka, SinkServer}
+
+func validateOTLPSink(p *problems, key string, o OTLPSinkConfig) {
+	switch o.Protocol {
+	case ProtocolGRPC:
+		validateTarget(p, key+".endpoint", o.Endpoint)
+	case ProtocolHTTP:
+		validateURL(p, key+".endpoint", o.Endpoint)
+	default:
+		p.add(key+".protocol", "must be %q or %q, got %q", ProtocolGRPC, ProtocolHTTP, o.Protocol)
+	}
+	validateTLS(p, key+".tls", o.TLS)
+	if o.Timeout <= 0 {
+		p.add(key+".timeout", "must be positive, got %s", o.Timeout)
+	}
+	if o.BatchSize <= 0 {
+		p.add(key+".batch_size", "must be positive, got %d", o.BatchSize)
+	}
+	validateRetry(p, key+".retry", o.Retry)
+}
+
+func validateWebhookSink(p *problems, key string, w WebhookSinkConfig) {
+	if w.URL == "" {
+		p.add(key+".url", "required")
+	} else {
+		validateURL(p, key+".url", w.URL)
+	}
+	if w.Format != FormatJSON && w.Format != FormatNDJSON {
+		p.add(key+".format", "must be %q or %q, got %q", FormatJSON, FormatNDJSON, w.Format)
+	}
+	validateTLS(p, key+".tls", w.TLS)
+	if w.Timeout <= 0 {
+		p.add(key+".timeout", "must be positive, got %s", w.Timeout)
+	}
+	if w.BatchSize <= 0 {
+		p.add(key+".batch_size", "must be positive, got %d", w.BatchSize)
+	}
+	validateRetry(p, key+".retry", w.Retry)
+	if w.Breaker.Failures > 0 && w.Breaker.Cooldown <= 0 {
+		p.add(key+".breaker.cooldown", "must be positive, got %s", w.Breaker.Cooldown)
+	}
+}
+
+func validateLokiSink(p *problems, key string, l LokiSinkConfig) {
+	if l.URL == "" {
+		p.add(key+".url", "required")
+	} else {
+		validateURL(p, key+".url", l.URL)
+	}
+	validateTLS(p, key+".tls", l.TLS)
+	for _, label := range l.Labels {
+		if !slices.Contains(LokiLabels, label) {
+			p.add(key+".labels", "unknown label %q, expected one of %s", label, strings.Join(LokiLabels, ", "))
+		}
+	}
+	for name := range l.StaticLabels {
+		if !labelName.MatchString(name) {
+			p.add(key+".static_labels", "invalid label name %q", name)
+		}
+	}
+	if len(l.Labels) == 0 && len(l.StaticLabels) == 0 {
+		p.add(key+".labels", "at least one label or static label is required")
+	}
+	if l.Format != FormatLogfmt && l.Format != FormatJSON {
+		p.add(key+".format", "must be %q or %q, got %q", FormatLogfmt, FormatJSON, l.Format)
+	}
+	if l.Timeout <= 0 {
+		p.add(key+".timeout", "must be positive, got %s", l.Timeout)
+	}
+	if l.BatchBytes <= 0 {
+		p.add(key+".batch_bytes", "must be positive, got %d", l.BatchBytes)
+	}
+	validateRetry(p, key+".retry", l.Retry)
+}
+
+func validateOpenSearchSink(p *problems, key string, o OpenSearchSinkConfig) {
+	if o.URL == "" {
+		p.add(key+".url", "required")
+	} else {
+		validateURL(p, key+".url", o.URL)
+	}
+	validateTLS(p, key+".tls", o.TLS)
+	if o.Password != "" && o.Username == "" {
+		p.add(key+".username", "required because password is set (%s)", p.origins.of(key+".password"))
+	}
+	opening, closing := strings.Index(o.Index, "{"), strings.Index(o.Index, "}")
+	if strings.Count(o.Index, "{") > 1 || strings.Count(o.Index, "}") > 1 || (opening < 0) != (closing < 0) || closing < opening {
+		p.add(key+".index", "may contain one time layout in braces, got %q", o.Index)
+	} else if name := o.IndexName(time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC)); strings.ToLower(name) != name || strings.ContainsAny(name, ` "*\\<|,>/?#:`) {
+		p.add(key+".index", "must name lowercase indices without spaces or any of \\/*?\"<>|,#:, got %q", name)
+	}
+	if o.Timeout <= 0 {
+		p.add(key+".timeout", "must be positive, got %s", o.Timeout)
+	}
+	if o.BatchSize <= 0 {
+		p.add(key+".batch_size", "must be positive, got %d", o.BatchSize)
+	}
+	validateRetry(p, key+".retry", o.Retry)
+}
+
+// labelName matches Prometheus and Loki label names.
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func validateRetry(p *problems, key string, r RetryConfig) {
+	if r.MaxAttempts < 1 {
+		p.add(key+".max_attempts", "must be at least 1, got %d", r.MaxAttempts)
+	}
+	validateBackoff(p, key+".backoff", r.Backoff)
+}
+
+// validateURL checks for an absolute http or https URL.
+func validateURL(p *problems, key string, value string) {
+	u, err := url.Parse(value)
+	if err != nil {
+		p.add(key, "invalid URL %q: %v", value, err)
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		p.add(key, "expected an http:// or https:// URL, got %q", value)
+	}
+}
+
+// Validate checks the settings of a stdout sink set up outside of a
+// configuration, such as by the flags of a command.
+func (s StdoutSinkConfig) Validate() error {
+	p := &problems{origins: origins{"stdout": "command line"}}
+	validateStdoutSink(p, "stdout", s)
+	return p.err()
+}
+
+func validateStdoutSink(p *problems, key string, s StdoutSinkConfig) {
+	if s.Format != FormatTable && s.Format != FormatCompact {
+		p.add(key+".format", "must be %q or %q, got %q", FormatTable, FormatCompact, s.Format)
+	}
+	for _, column := range s.Columns {
+		if !slices.Contains(StdoutColumns, column) {
+			p.add(key+".columns", "unknown column %q, expected one of %s", column, strings.Join(StdoutColumns, ", "))
+		}
+	}
+	if s.MaxWidth < 4 {
+		p.add(key+".max_width", "must be at least 4, got %d", s.MaxWidth)
+	}
+	if s.Color != ColorAuto && s.Color != ColorAlways && s.Color != ColorNever {
+		p.add(key+".color", "must be %q, %q or %q, got %q", ColorAuto, ColorAlways, ColorNever, s.Color)
+	}
+}
+
+func validateMetricsSink(p *problems, key string, m MetricsSinkConfig) {
+	if m.MaxValues <= 0 {
+		p.add(key+".max_values", "must be positive, got %d", m.MaxValues)
+	}
+	for i, bound := range m.Buckets {
+		if bound <= 0 || i > 0 && bound <= m.Buckets[i-1] {
+			p.add(key+".buckets", "must be positive and increasing, got %v", m.Buckets)
+			break
+		}
+	}
+}
+
+func validateParquetSink(p *problems, key string, pq ParquetSinkConfig) {
+	if pq.Dir == "" {
+		p.add(key+".dir", "required")
+	} else if info, err := os.Stat(pq.Dir); err != nil {
+		p.add(key+".dir", "cannot use %s: %v", pq.Dir, unwrapPathError(err))
+	} else if !info.IsDir() {
+		p.add(key+".dir", "%s is not a directory", pq.Dir)
+	}
+	if pq.MaxRows <= 0 {
+		p.add(key+".max_rows", "must be positive, got %d", pq.MaxRows)
+	}
+	if pq.MaxAge <= 0 {
+		p.add(key+".max_age", "must be positive, got %s", pq.MaxAge)
+	}
+	if !slices.Contains(ParquetCompressions, pq.Compression) {
+		p.add(key+".compression", "must be one of %s, got %q", strings.Join(ParquetCompressions, ", "), pq.Compression)
+	}
+}
+
+func validateKafkaSink(p *problems, key string, k KafkaSinkConfig) {
+	if len(k.Brokers) == 0 {
+		p.add(key+".brokers", "required")
+	}
+	for i, broker := range k.Brokers {
+		validateHostPort(p, fmt.Sprintf("%s.brokers[%d]", key, i), broker)
+	}
+	if k.Topic == "" {
+		p.add(key+".topic", "required")
+	} else if len(k.Topic) > 249 || strings.Trim(k.Topic, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789._-") != "" {
+		p.add(key+".topic", "must be at most 249 letters, digits, '.', '_' or '-', got %q", k.Topic)
+	}
+	validateTLS(p, key+".tls", k.TLS)
+	switch k.SASL.Mechanism {
+	case "":
+		if k.SASL.Username != "" || k.SASL.Password != "" {
+			p.add(key+".sasl.mechanism", "required because a username or password is set")
+		}
+	case "plain", "scram-sha-256", "scram-sha-512":
+		if k.SASL.Username == "" {
+			p.add(key+".sasl.username", "required by %s", k.SASL.Mechanism)
+		}
+		if k.SASL.Password == "" {
+			p.add(key+".sasl.password", "required by %s", k.SASL.Mechanism)
+		}
+	default:
+		p.add(key+".sasl.mechanism", "must be %q, %q or %q, got %q", "plain", "scram-sha-256", "scram-sha-512", k.SASL.Mechanism)
+	}
+	if k.Format != FormatProtobuf && k.Format != FormatJSON {
+		p.add(key+".format", "must be %q or %q, got %q", FormatProtobuf, FormatJSON, k.Format)
+	}
+	if k.Key != "" && !slices.Contains(KafkaKeys, k.Key) {
+		p.add(key+".key", "unknown field %q, expected one of %s", k.Key, strings.Join(KafkaKeys, ", "))
+	}
+	if k.Acks != AcksAll && k.Acks != AcksLeader && k.Acks != AcksNone {
+		p.add(key+".acks", "must be %q, %q or %q, got %q", AcksAll, AcksLeader, AcksNone, k.Acks)
+	}
+	if !slices.Contains(KafkaPartitioners, k.Partitioner) {
+		p.add(key+".partitioner", "must be one of %s, got %q", strings.Join(KafkaPartitioners, ", "), k.Partitioner)
+	}
+	if !slices.Contains(KafkaCompressions, k.Compression) {
+		p.add(key+".compression", "must be one of %s, got %q", strings.Join(KafkaCompressions, ", "), k.Compression)
+	}
+	if k.Linger < 0 {
+		p.add(key+".linger", "must not be negative, got %s", k.Linger)
+	}
+	if k.Timeout <= 0 {
+		p.add(key+".timeout", "must be positive, got %s", k.Timeout)
+	}
+	validateRetry(p, key+".retry", k.Retry)
+}
+
+func validateSubscriptionsSink(p *problems, key string, s SubscriptionsSinkConfig) {
+	switch {
+	case s.Addr == "":
+		p.add(key+".addr", "required")
+	case strings.HasPrefix(s.Addr, "unix:"):
+		if strings.TrimPrefix(s.Addr, "unix:") == "" {
+			p.add(key+".addr", "expected unix:/path, got %q", s.Addr)
+		}
+	default:
+		validateHostPort(p, key+".addr", s.Addr)
+		if host, _, err := net.SplitHostPort(s.Addr); err == nil && !s.AllowRemote && !isLoopback(host) {
+			p.add(key+".addr", "%q is reachable from other hosts and the server has no authentication, listen on localhost, 127.0.0.1, [::1] or a Unix socket, or set %s.allow_remote", s.Addr, key)
+		}
+	}
+	if s.Buffer <= 0 {
+		p.add(key+".buffer", "must be positive, got %d", s.Buffer)
+	}
+	if s.MaxSubscribers <= 0 {
+		p.add(key+".max_subscribers", "must be positive, got %d", s.MaxSubscribers)
+	}
+}
+
+func validateFileSink(p *problems, key string, f FileSinkConfig) {
+	if f.Path == "" {
+		p.add(key+".path", "required")
+	} else if info, err := os.Stat(filepath.Dir(f.Path)); err != nil {
+		p.add(key+".path", "cannot use directory of %s: %v", f.Path, unwrapPathError(err))
+	} else if !info.IsDir() {
+		p.add(key+".path", "%s is not a directory", filepath.Dir(f.Path))
+	}
+	validateNonNegative(p, key+".max_age", f.MaxAge)
+}
+
+// usesGateway reports whether any part of the configuration needs the event gateway.
+func (c *Config) usesGateway() bool {
+	if c.Remote.Enabled {
+		return true
+	}
+	for _, sink := range c.Sinks {
+		if sink.Type == SinkGateway {
+			return true
+		}
+	}
+	return false
+}
+
+// validateQueue checks q. When it inherits from a parent, which has already
+// been validated, only the fields that differ from the parent are checked.
+func validateQueue(p *problems, key string, q QueueConfig, parent *QueueConfig) {
+	var base QueueConfig
+	if parent != nil {
+		base = *parent
+	}
+	own := func(equal bool) bool { return parent == nil || !equal }
+
+	if own(q.Size == base.Size) && q.Size <= 0 {
+		p.add(key+".size", "must be positive, got %d", q.Size)
+	}
+	if own(q.Overflow == base.Overflow) && q.Overflow != OverflowBlock && q.Overflow != OverflowDrop {
+		p.add(key+".overflow", "must be %q or %q, got %q", OverflowBlock, OverflowDrop, q.Overflow)
+	}
+	if own(q.DrainDeadline == base.DrainDeadline) {
+		validateNonNegative(p, key+".drain_deadline", q.DrainDeadline)
+	}
+	if own(q.FlushInterval == base.FlushInterval) && q.FlushInterval <= 0 {
+		p.add(key+".flush_interval", "must be positive, got %s", q.FlushInterval)
+	}
+}
+
+func validateBackoff(p *problems, key string, b BackoffConfig) {
+	if b.Base <= 0 {
+		p.add(key+".base", "must be positive, got %s", b.Base)
+	}
+	if b.Max < b.Base {
+		p.add(key+".max", "must not be less than %s.base (%s), got %s", key, b.Base, b.Max)
+	}
+}
+
+func validateNonNegative(p *problems, key string, d Duration) {
+	if d < 0 {
+		p.add(key, "must not be negative, got %s", d)
+	}
+}
+
+func validateTLS(p *problems, key string, t TLSConfig) {
+	switch {
+	case t.CertFile != "" && t.KeyFile == "":
+		p.add(key+".key_file", "required because cert_file is set (%s)", p.origins.of(key+".cert_file"))
+	case t.CertFile == "" && t.KeyFile != "":
+		p.add(key+".cert_file", "required because key_file is set (%s)", p.origins.of(key+".key_file"))
+	}
+	if !t.Enabled {
+		for _, file := range []struct{ key, path string }{{"ca_file", t.CAFile}, {"cert_file", t.CertFile}, {"key_file", t.KeyFile}} {
+			if file.path != "" {
+				p.warn(key+"."+file.key, "ignored because TLS is disabled, set %s.enabled to use it", key)
+			}
+		}
+		return
+	}
+	if t.CAFile != "" {
+		validateReadable(p, key+".ca_file", t.CAFile)
+	}
+	if t.CertFile != "" {
+		validateReadable(p, key+".cert_file", t.CertFile)
+	}
+	if t.KeyFile != "" {
+		validateReadable(p, key+".key_file", t.KeyFile)
+	}
+}
+
+func validateProcessor(p *problems, key string, cfg ProcessorConfig) {
+	filterSet := len(cfg.Namespaces) > 0 || len(cfg.ExcludeNamespaces) > 0 ||
+		len(cfg.Services) > 0 || len(cfg.ExcludeServices) > 0 || len(cfg.Protocols) > 0
+
+	switch cfg.Type {
+	case "filter":
+		if cfg.Rate != 0 {
+			p.add(key+".rate", "only applies to sample and rate_limit processors")
+		}
+		if cfg.Burst != 0 {
+			p.add(key+".burst", "only applies to rate_limit processors")
+		}
+		for _, protocol := range cfg.Protocols {
+			if !slices.Contains(event.Protocols, protocol) {
+				p.add(key+".protocols", "unknown protocol %q, expected one of %s", protocol, strings.Join(event.Protocols, ", "))
+			}
+		}
+	case "sample":
+		if filterSet {
+			p.add(key, "namespaces, services and protocols only apply to filter processors")
+		}
+		if cfg.Rate <= 0 || cfg.Rate > 1 {
+			p.add(key+".rate", "must be greater than 0 and at most 1, got %g", cfg.Rate)
+		}
+		if cfg.Burst != 0 {
+			p.add(key+".burst", "only applies to rate_limit processors")
+		}
+	case "rate_limit":
+		if filterSet {
+			p.add(key, "namespaces, services and protocols only apply to filter processors")
+		}
+		if cfg.Rate <= 0 {
+			p.add(key+".rate", "must be greater than 0 events per second, got %g", cfg.Rate)
+		}
+		if cfg.Burst < 0 {
+			p.add(key+".burst", "must not be negative, got %d", cfg.Burst)
+		}
+	default:
+		p.add(key+".type", "must be %q, %q or %q, got %q", "filter", "sample", "rate_limit", cfg.Type)
+	}
+}
+
+// validateHostPort checks for a "host:port" address with a numeric port.
+func validateHostPort(p *problems, key string, value string) {
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		p.add(key, "expected host:port, got %q", value)
+		return
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		p.add(key, "invalid port %q in %q", port, value)
+	}
+}
+
+// isLoopback reports whether a listener on host is reachable only from this
+// host. An empty host listens on every interface.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// validateTarget accepts either host:port or a gRPC target URI such as dns:///host:port.
+func validateTarget(p *problems, key string, value string) {
+	if strings.Contains(value, "://") {
+		if _, err := url.Parse(value); err != nil {
+			p.add(key, "invalid target %q: %v", value, err)
+		}
+		return
+	}
+	validateHostPort(p, key, value)
+}
+
+func validateReadable(p *problems, key string, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		p.add(key, "cannot read %s: %v", path, unwrapPathError(err))
+		return
+	}
+	f.Close()
+}
+
+func unwrapPathError(err error) error {
+	if pathErr, ok := err.(*os.PathError); ok {
+		return pathErr.Err
+	}
+	return err
+}
@@ -0,0 +1,37 @@
+package event
+
+import (
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+// Protocols lists every protocol name returned by Protocol.
+var Protocols = []string{"http", "pgsql", "mysql", "redis", "kafka", "dns", "nats", "amqp", "cql", "mux"}
+
+// Protocol returns the name of the protocol carried by the event, matching
+// the oneof field name, or an empty string when no protocol data is set.
+func Protocol(e *pb.PixieEvent) string {
+	switch e.GetProtocolData().(type) {
+	case *pb.PixieEvent_Http:
+		return "http"
+	case *pb.PixieEvent_Pgsql:
+		return "pgsql"
+	case *pb.PixieEvent_Mysql:
+		return "mysql"
+	case *pb.PixieEvent_Redis:
+		return "redis"
+	case *pb.PixieEvent_Kafka:
+		return "kafka"
+	case *pb.PixieEvent_Dns:
+		return "dns"
+	case *pb.PixieEvent_Nats:
+		return "nats"
+	case *pb.PixieEvent_Amqp:
+		return "amqp"
+	case *pb.PixieEvent_Cql:
+		return "cql"
+	case *pb.PixieEvent_Mux:
+		return "mux"
+	default:
+		return ""
+	}
+}
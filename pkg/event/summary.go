@@ -0,0 +1,98 @@
+package event
+
+import (
+	"strconv"
+	"strings"
+
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+// Request summarises the request carried by the event, e.g. "GET /health"
+// for HTTP or the statement for SQL protocols.
+func Request(e *pb.PixieEvent) string {
+	switch p := e.GetProtocolData().(type) {
+	case *pb.PixieEvent_Http:
+		return join(p.Http.GetReqMethod(), p.Http.GetReqPath())
+	case *pb.PixieEvent_Pgsql:
+		return join(p.Pgsql.GetReqCmd(), p.Pgsql.GetReq())
+	case *pb.PixieEvent_Mysql:
+		return p.Mysql.GetReqBody()
+	case *pb.PixieEvent_Redis:
+		return join(p.Redis.GetReqCmd(), p.Redis.GetReqArgs())
+	case *pb.PixieEvent_Kafka:
+		return join("cmd "+strconv.FormatInt(p.Kafka.GetReqCmd(), 10), p.Kafka.GetClientId())
+	case *pb.PixieEvent_Dns:
+		return p.Dns.GetReqBody()
+	case *pb.PixieEvent_Nats:
+		return join(p.Nats.GetCmd(), p.Nats.GetBody())
+	case *pb.PixieEvent_Amqp:
+		return p.Amqp.GetReqMsg()
+	case *pb.PixieEvent_Cql:
+		return p.Cql.GetReqBody()
+	case *pb.PixieEvent_Mux:
+		return "type " + strconv.FormatInt(p.Mux.GetReqType(), 10)
+	default:
+		return ""
+	}
+}
+
+// Status returns the response status code of protocols that have one.
+func Status(e *pb.PixieEvent) (int64, bool) {
+	switch p := e.GetProtocolData().(type) {
+	case *pb.PixieEvent_Http:
+		return int64(p.Http.GetRespStatus()), true
+	case *pb.PixieEvent_Mysql:
+		return p.Mysql.GetRespStatus(), true
+	default:
+		return 0, false
+	}
+}
+
+// Side returns "server" when Pixie traced the server side of the connection,
+// and "client" otherwise.
+func Side(e *pb.PixieEvent) string {
+	if e.GetIsServerSideTracing() {
+		return "server"
+	}
+	return "client"
+}
+
+// MySQLError is the response status Pixie records for MySQL error packets.
+const MySQLError = 3
+
+// Failed reports whether the event's response is an error: an HTTP 5xx
+// response or a MySQL error packet.
+func Failed(e *pb.PixieEvent) bool {
+	switch p := e.GetProtocolData().(type) {
+	case *pb.PixieEvent_Http:
+		return p.Http.GetRespStatus() >= 500
+	case *pb.PixieEvent_Mysql:
+		return p.Mysql.GetRespStatus() == MySQLError
+	default:
+		return false
+	}
+}
+
+// StatusClass returns the class of an HTTP response status, e.g. "2xx", or
+// an empty string for other protocols and unknown statuses.
+func StatusClass(e *pb.PixieEvent) string {
+	h, ok := e.GetProtocolData().(*pb.PixieEvent_Http)
+	if !ok {
+		return ""
+	}
+	status := h.Http.GetRespStatus()
+	if status < 100 || status > 599 {
+		return ""
+	}
+	return strconv.Itoa(int(status/100)) + "xx"
+}
+
+func join(parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, " ")
+}
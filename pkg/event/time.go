@@ -0,0 +1,20 @@
+package event
+
+import (
+	"strconv"
+	"time"
+
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+// Time parses the time the event was observed, given either as an RFC 3339
+// timestamp or as nanoseconds since the Unix epoch.
+func Time(e *pb.PixieEvent) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, e.GetTime()); err == nil {
+		return t, true
+	}
+	if ns, err := strconv.ParseInt(e.GetTime(), 10, 64); err == nil {
+		return time.Unix(0, ns), true
+	}
+	return time.Time{}, false
+}
@@ -19,3 +19,12 @@ func (s *ServiceClient) RegisterClient(conn *grpc.ClientConn) {
 func (s *ServiceClient) StreamEvents(ctx context.Context) (pb.EventGatewayService_StreamEventsClient, error) {
 	return s.client.StreamEvents(ctx)
 }
+
+func (s *ServiceClient) WatchConfig(ctx context.Context, identity *pb.ObserverIdentity) (pb.EventGatewayService_WatchConfigClient, error) {
+	return s.client.WatchConfig(ctx, identity)
+}
+
+func (s *ServiceClient) ReportConfig(ctx context.Context, report *pb.ConfigReport) error {
+	_, err := s.client.ReportConfig(ctx, report)
+	return err
+}
@@ -0,0 +1,107 @@
+package eventgateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WatchRemoteConfig passes every configuration the gateway sends for the
+// observer to apply until ctx is done, and reports back whether it was applied.
+// While the gateway cannot be reached it retries with backoff, and the
+// observer keeps running on the last configuration applied. A gateway that
+// does not serve remote configuration ends the watch.
+func WatchRemoteConfig(ctx context.Context, client *ServiceClient, cfg *config.Config, apply func(config.Remote) error) {
+	identity := &pb.ObserverIdentity{
+		ObserverId: cfg.Remote.ObserverID,
+		ApiKey:     cfg.Gateway.APIKey.Value(),
+	}
+
+	attempt := 0
+	for {
+		received, err := watchRemoteConfig(ctx, client, identity, apply)
+		if ctx.Err() != nil {
+			return
+		}
+		if status.Code(err) == codes.Unimplemented {
+			log.Warn().Msg("Event gateway does not serve remote configuration, keeping the local configuration")
+			return
+		}
+
+		if received {
+			attempt = 0
+		}
+		attempt++
+		delay := cfg.Remote.Backoff.Delay(attempt)
+		log.Error().Err(err).Dur("retry_in", delay).Msg("Lost remote configuration stream, keeping the last applied configuration")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// watchRemoteConfig follows a single WatchConfig stream until it fails,
+// reporting whether any configuration was received.
+func watchRemoteConfig(ctx context.Context, client *ServiceClient, identity *pb.ObserverIdentity, apply func(config.Remote) error) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.WatchConfig(ctx, identity)
+	if err != nil {
+		return false, err
+	}
+
+	received := false
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return received, errors.New("stream closed by the event gateway")
+		}
+		if err != nil {
+			return received, err
+		}
+		received = true
+
+		report := &pb.ConfigReport{Identity: identity, Version: msg.GetVersion(), Applied: true}
+		if err := apply(remoteConfig(msg)); err != nil {
+			report.Applied = false
+			report.Error = err.Error()
+		}
+		if err := client.ReportConfig(ctx, report); err != nil {
+			log.Warn().Err(err).Str("version", msg.GetVersion()).Msg("Error reporting remote configuration")
+		}
+	}
+}
+
+func remoteConfig(msg *pb.ObserverConfig) config.Remote {
+	remote := config.Remote{Version: msg.GetVersion()}
+	for _, script := range msg.GetScripts() {
+		remote.Scripts = append(remote.Scripts, config.Script{Name: script.GetName(), PxL: script.GetPxl()})
+	}
+	for _, processor := range msg.GetProcessors() {
+		remote.Processors = append(remote.Processors, config.ProcessorConfig{
+			Type:              processor.GetType(),
+			Namespaces:        processor.GetNamespaces(),
+			ExcludeNamespaces: processor.GetExcludeNamespaces(),
+			Services:          processor.GetServices(),
+			ExcludeServices:   processor.GetExcludeServices(),
+			Protocols:         processor.GetProtocols(),
+			Rate:              processor.GetRate(),
+			Burst:             int(processor.GetBurst()),
+		})
+	}
+	return remote
+}
@@ -0,0 +1,116 @@
+package eventgateway
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+// fakeGateway pushes configs to a watching observer and collects its reports.
+type fakeGateway struct {
+	pb.UnimplementedEventGatewayServiceServer
+	configs chan *pb.ObserverConfig
+	reports chan *pb.ConfigReport
+}
+
+func (g *fakeGateway) WatchConfig(identity *pb.ObserverIdentity, stream pb.EventGatewayService_WatchConfigServer) error {
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case msg := <-g.configs:
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (g *fakeGateway) ReportConfig(ctx context.Context, report *pb.ConfigReport) (*emptypb.Empty, error) {
+	g.reports <- report
+	return &emptypb.Empty{}, nil
+}
+
+// dialFakeGateway serves gateway in memory and returns a client connected to it.
+func dialFakeGateway(t *testing.T, gateway pb.EventGatewayServiceServer) *ServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterEventGatewayServiceServer(server, gateway)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	client := &ServiceClient{}
+	client.RegisterClient(conn)
+	return client
+}
+
+func TestWatchRemoteConfigReportsRejectedConfig(t *testing.T) {
+	gateway := &fakeGateway{configs: make(chan *pb.ObserverConfig, 2), reports: make(chan *pb.ConfigReport)}
+	client := dialFakeGateway(t, gateway)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := &config.Config{Remote: config.RemoteConfig{ObserverID: "observer-1"}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WatchRemoteConfig(ctx, client, cfg, func(remote config.Remote) error {
+			if remote.Scripts[0].PxL == "broken" {
+				return errors.New("script http: does not compile")
+			}
+			return nil
+		})
+	}()
+
+	gateway.configs <- &pb.ObserverConfig{Version: "1", Scripts: []*pb.ObserverScript{{Name: "http", Pxl: "broken"}}}
+	gateway.configs <- &pb.ObserverConfig{Version: "2", Scripts: []*pb.ObserverScript{{Name: "http", Pxl: "import px"}}}
+
+	want := []struct {
+		version string
+		applied bool
+		err     string
+	}{
+		{"1", false, "script http: does not compile"},
+		{"2", true, ""},
+	}
+	for _, w := range want {
+		select {
+		case report := <-gateway.reports:
+			if report.GetVersion() != w.version || report.GetApplied() != w.applied || report.GetError() != w.err {
+				t.Errorf("report = version %q, applied %t, error %q, want version %q, applied %t, error %q",
+					report.GetVersion(), report.GetApplied(), report.GetError(), w.version, w.applied, w.err)
+			}
+			if report.GetIdentity().GetObserverId() != "observer-1" {
+				t.Errorf("report identity = %q, want observer-1", report.GetIdentity().GetObserverId())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no report of version %s", w.version)
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchRemoteConfig did not return once ctx was done")
+	}
+}
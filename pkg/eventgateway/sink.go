@@ -0,0 +1,134 @@
+package eventgateway
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var errSendTimeout = errors.New("timed out sending to the gateway")
+
+// Sink streams events to the event gateway. A failed stream is replaced on
+// the next Send. Sink is not safe for concurrent use.
+type Sink struct {
+	client       *ServiceClient
+	apiKey       config.Secret
+	sendTimeout  time.Duration
+	stream       pb.EventGatewayService_StreamEventsClient
+	cancelStream context.CancelFunc // Cancels the stream's context, unblocking a stuck send
+
+	mu  sync.Mutex
+	err error // Last send error, reported by Health
+}
+
+// NewSink opens an event stream to the gateway.
+func NewSink(client *ServiceClient, cfg *config.Config) (*Sink, error) {
+	s := &Sink{
+		client:      client,
+		apiKey:      cfg.Gateway.APIKey,
+		sendTimeout: cfg.Gateway.SendTimeout.Duration(),
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open creates the stream. Its context is independent of the caller's, so
+// that queued events can still be sent while shutting down.
+func (s *Sink) open() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := s.client.StreamEvents(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	s.stream = stream
+	s.cancelStream = cancel
+	return nil
+}
+
+func (s *Sink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	err := s.send(ctx, e)
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+	return err
+}
+
+// send sends e with the API key set, cancelling the stream if it blocks for
+// longer than the send timeout or ctx is done. A stream that failed is discarded.
+func (s *Sink) send(ctx context.Context, e *pb.PixieEvent) error {
+	if s.stream == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, s.cancelStream)
+	defer stop()
+
+	msg := proto.Clone(e).(*pb.PixieEvent)
+	msg.ApiKey = s.apiKey.Value()
+
+	err := s.sendWithTimeout(msg)
+	if err != nil {
+		s.cancelStream()
+		s.stream = nil
+	}
+	return err
+}
+
+func (s *Sink) sendWithTimeout(msg *pb.PixieEvent) error {
+	if s.sendTimeout <= 0 {
+		return s.stream.Send(msg)
+	}
+
+	timedOut := false
+	var mu sync.Mutex
+	timer := time.AfterFunc(s.sendTimeout, func() {
+		mu.Lock()
+		timedOut = true
+		mu.Unlock()
+		s.cancelStream()
+	})
+	err := s.stream.Send(msg)
+	timer.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if timedOut {
+		return errSendTimeout
+	}
+	return err
+}
+
+// Flush is a no-op, every event is sent as it arrives.
+func (s *Sink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close half-closes the stream and waits for the gateway to acknowledge it.
+func (s *Sink) Close(ctx context.Context) error {
+	if s.stream == nil {
+		return nil
+	}
+	stop := context.AfterFunc(ctx, s.cancelStream)
+	defer stop()
+	defer s.cancelStream()
+
+	_, err := s.stream.CloseAndRecv()
+	s.stream = nil
+	return err
+}
+
+func (s *Sink) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
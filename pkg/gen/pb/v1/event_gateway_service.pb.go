@@ -11,6 +11,7 @@ import (
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 	reflect "reflect"
+	sync "sync"
 )
 
 const (
@@ -20,6 +21,363 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// ObserverIdentity identifies an observer to the gateway.
+type ObserverIdentity struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Chosen by the observer, stable across restarts
+	ObserverId string `protobuf:"bytes,1,opt,name=observer_id,json=observerId,proto3" json:"observer_id,omitempty"`
+	ApiKey     string `protobuf:"bytes,2,opt,name=api_key,json=apiKey,proto3" json:"api_key,omitempty"`
+}
+
+func (x *ObserverIdentity) Reset() {
+	*x = ObserverIdentity{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ObserverIdentity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ObserverIdentity) ProtoMessage() {}
+
+func (x *ObserverIdentity) ProtoReflect() protoreflect.Message {
+	mi := &file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ObserverIdentity.ProtoReflect.Descriptor instead.
+func (*ObserverIdentity) Descriptor() ([]byte, []int) {
+	return file_com_orbservability_schema_v1_event_gateway_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ObserverIdentity) GetObserverId() string {
+	if x != nil {
+		return x.ObserverId
+	}
+	return ""
+}
+
+func (x *ObserverIdentity) GetApiKey() string {
+	if x != nil {
+		return x.ApiKey
+	}
+	return ""
+}
+
+// ObserverConfig is the part of an observer's configuration managed by the gateway.
+type ObserverConfig struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Opaque to the observer, reported back once applied
+	Version string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	// Replace the observer's local scripts, unless empty
+	Scripts []*ObserverScript `protobuf:"bytes,2,rep,name=scripts,proto3" json:"scripts,omitempty"`
+	// Replace the observer's local processors
+	Processors []*ObserverProcessor `protobuf:"bytes,3,rep,name=processors,proto3" json:"processors,omitempty"`
+}
+
+func (x *ObserverConfig) Reset() {
+	*x = ObserverConfig{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ObserverConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ObserverConfig) ProtoMessage() {}
+
+func (x *ObserverConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ObserverConfig.ProtoReflect.Descriptor instead.
+func (*ObserverConfig) Descriptor() ([]byte, []int) {
+	return file_com_orbservability_schema_v1_event_gateway_service_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ObserverConfig) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *ObserverConfig) GetScripts() []*ObserverScript {
+	if x != nil {
+		return x.Scripts
+	}
+	return nil
+}
+
+func (x *ObserverConfig) GetProcessors() []*ObserverProcessor {
+	if x != nil {
+		return x.Processors
+	}
+	return nil
+}
+
+type ObserverScript struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Pxl  string `protobuf:"bytes,2,opt,name=pxl,proto3" json:"pxl,omitempty"`
+}
+
+func (x *ObserverScript) Reset() {
+	*x = ObserverScript{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ObserverScript) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ObserverScript) ProtoMessage() {}
+
+func (x *ObserverScript) ProtoReflect() protoreflect.Message {
+	mi := &file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ObserverScript.ProtoReflect.Descriptor instead.
+func (*ObserverScript) Descriptor() ([]byte, []int) {
+	return file_com_orbservability_schema_v1_event_gateway_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ObserverScript) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ObserverScript) GetPxl() string {
+	if x != nil {
+		return x.Pxl
+	}
+	return ""
+}
+
+// ObserverProcessor mirrors the processors section of the observer's configuration file.
+type ObserverProcessor struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// "filter", "sample" or "rate_limit"
+	Type              string   `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Namespaces        []string `protobuf:"bytes,2,rep,name=namespaces,proto3" json:"namespaces,omitempty"`
+	ExcludeNamespaces []string `protobuf:"bytes,3,rep,name=exclude_namespaces,json=excludeNamespaces,proto3" json:"exclude_namespaces,omitempty"`
+	Services          []string `protobuf:"bytes,4,rep,name=services,proto3" json:"services,omitempty"`
+	ExcludeServices   []string `protobuf:"bytes,5,rep,name=exclude_services,json=excludeServices,proto3" json:"exclude_services,omitempty"`
+	Protocols         []string `protobuf:"bytes,6,rep,name=protocols,proto3" json:"protocols,omitempty"`
+	Rate              float64  `protobuf:"fixed64,7,opt,name=rate,proto3" json:"rate,omitempty"`
+	Burst             int32    `protobuf:"varint,8,opt,name=burst,proto3" json:"burst,omitempty"`
+}
+
+func (x *ObserverProcessor) Reset() {
+	*x = ObserverProcessor{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ObserverProcessor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ObserverProcessor) ProtoMessage() {}
+
+func (x *ObserverProcessor) ProtoReflect() protoreflect.Message {
+	mi := &file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ObserverProcessor.ProtoReflect.Descriptor instead.
+func (*ObserverProcessor) Descriptor() ([]byte, []int) {
+	return file_com_orbservability_schema_v1_event_gateway_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ObserverProcessor) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ObserverProcessor) GetNamespaces() []string {
+	if x != nil {
+		return x.Namespaces
+	}
+	return nil
+}
+
+func (x *ObserverProcessor) GetExcludeNamespaces() []string {
+	if x != nil {
+		return x.ExcludeNamespaces
+	}
+	return nil
+}
+
+func (x *ObserverProcessor) GetServices() []string {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *ObserverProcessor) GetExcludeServices() []string {
+	if x != nil {
+		return x.ExcludeServices
+	}
+	return nil
+}
+
+func (x *ObserverProcessor) GetProtocols() []string {
+	if x != nil {
+		return x.Protocols
+	}
+	return nil
+}
+
+func (x *ObserverProcessor) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+func (x *ObserverProcessor) GetBurst() int32 {
+	if x != nil {
+		return x.Burst
+	}
+	return 0
+}
+
+// ConfigReport is the outcome of applying an ObserverConfig.
+type ConfigReport struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Identity *ObserverIdentity `protobuf:"bytes,1,opt,name=identity,proto3" json:"identity,omitempty"`
+	Version  string            `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	Applied  bool              `protobuf:"varint,3,opt,name=applied,proto3" json:"applied,omitempty"`
+	// Why the configuration was rejected, when not applied
+	Error string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *ConfigReport) Reset() {
+	*x = ConfigReport{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConfigReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigReport) ProtoMessage() {}
+
+func (x *ConfigReport) ProtoReflect() protoreflect.Message {
+	mi := &file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigReport.ProtoReflect.Descriptor instead.
+func (*ConfigReport) Descriptor() ([]byte, []int) {
+	return file_com_orbservability_schema_v1_event_gateway_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ConfigReport) GetIdentity() *ObserverIdentity {
+	if x != nil {
+		return x.Identity
+	}
+	return nil
+}
+
+func (x *ConfigReport) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *ConfigReport) GetApplied() bool {
+	if x != nil {
+		return x.Applied
+	}
+	return false
+}
+
+func (x *ConfigReport) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
 var File_com_orbservability_schema_v1_event_gateway_service_proto protoreflect.FileDescriptor
 
 var file_com_orbservability_schema_v1_event_gateway_service_proto_rawDesc = []byte{
@@ -33,30 +391,115 @@ var file_com_orbservability_schema_v1_event_gateway_service_proto_rawDesc = []by
 	0x65, 0x6d, 0x61, 0x2f, 0x76, 0x31, 0x2f, 0x70, 0x69, 0x78, 0x69, 0x65, 0x5f, 0x65, 0x76, 0x65,
 	0x6e, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1b, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
 	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x2e,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x32, 0x69, 0x0a, 0x13, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x47, 0x61,
-	0x74, 0x65, 0x77, 0x61, 0x79, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x52, 0x0a, 0x0c,
-	0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x28, 0x2e, 0x63,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x4c, 0x0a, 0x10, 0x4f, 0x62, 0x73, 0x65, 0x72, 0x76, 0x65,
+	0x72, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x1f, 0x0a, 0x0b, 0x6f, 0x62, 0x73,
+	0x65, 0x72, 0x76, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a,
+	0x6f, 0x62, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x61, 0x70,
+	0x69, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x70, 0x69,
+	0x4b, 0x65, 0x79, 0x22, 0xc3, 0x01, 0x0a, 0x0e, 0x4f, 0x62, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72,
+	0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x12, 0x46, 0x0a, 0x07, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x2c, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x6f, 0x72, 0x62, 0x73, 0x65, 0x72, 0x76, 0x61,
+	0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x2e, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x2e, 0x76, 0x31,
+	0x2e, 0x4f, 0x62, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x53, 0x63, 0x72, 0x69, 0x70, 0x74, 0x52,
+	0x07, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x73, 0x12, 0x4f, 0x0a, 0x0a, 0x70, 0x72, 0x6f, 0x63,
+	0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2f, 0x2e, 0x63,
+	0x6f, 0x6d, 0x2e, 0x6f, 0x72, 0x62, 0x73, 0x65, 0x72, 0x76, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74,
+	0x79, 0x2e, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x4f, 0x62, 0x73, 0x65,
+	0x72, 0x76, 0x65, 0x72, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x52, 0x0a, 0x70,
+	0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x22, 0x36, 0x0a, 0x0e, 0x4f, 0x62, 0x73,
+	0x65, 0x72, 0x76, 0x65, 0x72, 0x53, 0x63, 0x72, 0x69, 0x70, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
+	0x10, 0x0a, 0x03, 0x70, 0x78, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x70, 0x78,
+	0x6c, 0x22, 0x85, 0x02, 0x0a, 0x11, 0x4f, 0x62, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x50, 0x72,
+	0x6f, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x6e,
+	0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52,
+	0x0a, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x73, 0x12, 0x2d, 0x0a, 0x12, 0x65,
+	0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65,
+	0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x11, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65,
+	0x4e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x73, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x12, 0x29, 0x0a, 0x10, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64,
+	0x65, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x0f, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x73, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x73, 0x18, 0x06,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x73, 0x12,
+	0x12, 0x0a, 0x04, 0x72, 0x61, 0x74, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x72,
+	0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x75, 0x72, 0x73, 0x74, 0x18, 0x08, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x05, 0x62, 0x75, 0x72, 0x73, 0x74, 0x22, 0xa4, 0x01, 0x0a, 0x0c, 0x43, 0x6f,
+	0x6e, 0x66, 0x69, 0x67, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x4a, 0x0a, 0x08, 0x69, 0x64,
+	0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2e, 0x2e, 0x63,
 	0x6f, 0x6d, 0x2e, 0x6f, 0x72, 0x62, 0x73, 0x65, 0x72, 0x76, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74,
-	0x79, 0x2e, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x69, 0x78, 0x69,
-	0x65, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x28, 0x01,
-	0x42, 0x25, 0x5a, 0x23, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6f,
-	0x72, 0x62, 0x73, 0x65, 0x72, 0x76, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x2f, 0x73, 0x63,
-	0x68, 0x65, 0x6d, 0x61, 0x2f, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x79, 0x2e, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x4f, 0x62, 0x73, 0x65,
+	0x72, 0x76, 0x65, 0x72, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x52, 0x08, 0x69, 0x64,
+	0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x12, 0x18, 0x0a, 0x07, 0x61, 0x70, 0x70, 0x6c, 0x69, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x07, 0x61, 0x70, 0x70, 0x6c, 0x69, 0x65, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72,
+	0x72, 0x6f, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72,
+	0x32, 0xac, 0x02, 0x0a, 0x13, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x47, 0x61, 0x74, 0x65, 0x77, 0x61,
+	0x79, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x52, 0x0a, 0x0c, 0x53, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x28, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x6f,
+	0x72, 0x62, 0x73, 0x65, 0x72, 0x76, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x2e, 0x73, 0x63,
+	0x68, 0x65, 0x6d, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x69, 0x78, 0x69, 0x65, 0x45, 0x76, 0x65,
+	0x6e, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x28, 0x01, 0x12, 0x6d, 0x0a, 0x0b,
+	0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x2e, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2e, 0x6f, 0x72, 0x62, 0x73, 0x65, 0x72, 0x76, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79,
+	0x2e, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x4f, 0x62, 0x73, 0x65, 0x72,
+	0x76, 0x65, 0x72, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x1a, 0x2c, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2e, 0x6f, 0x72, 0x62, 0x73, 0x65, 0x72, 0x76, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79,
+	0x2e, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x4f, 0x62, 0x73, 0x65, 0x72,
+	0x76, 0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x30, 0x01, 0x12, 0x52, 0x0a, 0x0c, 0x52,
+	0x65, 0x70, 0x6f, 0x72, 0x74, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x2a, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2e, 0x6f, 0x72, 0x62, 0x73, 0x65, 0x72, 0x76, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79,
+	0x2e, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x42,
+	0x25, 0x5a, 0x23, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6f, 0x72,
+	0x62, 0x73, 0x65, 0x72, 0x76, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x2f, 0x73, 0x63, 0x68,
+	0x65, 0x6d, 0x61, 0x2f, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_com_orbservability_schema_v1_event_gateway_service_proto_rawDescOnce sync.Once
+	file_com_orbservability_schema_v1_event_gateway_service_proto_rawDescData = file_com_orbservability_schema_v1_event_gateway_service_proto_rawDesc
+)
+
+func file_com_orbservability_schema_v1_event_gateway_service_proto_rawDescGZIP() []byte {
+	file_com_orbservability_schema_v1_event_gateway_service_proto_rawDescOnce.Do(func() {
+		file_com_orbservability_schema_v1_event_gateway_service_proto_rawDescData = protoimpl.X.CompressGZIP(file_com_orbservability_schema_v1_event_gateway_service_proto_rawDescData)
+	})
+	return file_com_orbservability_schema_v1_event_gateway_service_proto_rawDescData
 }
 
+var file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_com_orbservability_schema_v1_event_gateway_service_proto_goTypes = []interface{}{
-	(*PixieEvent)(nil),    // 0: com.orbservability.schema.v1.PixieEvent
-	(*emptypb.Empty)(nil), // 1: google.protobuf.Empty
+	(*ObserverIdentity)(nil),  // 0: com.orbservability.schema.v1.ObserverIdentity
+	(*ObserverConfig)(nil),    // 1: com.orbservability.schema.v1.ObserverConfig
+	(*ObserverScript)(nil),    // 2: com.orbservability.schema.v1.ObserverScript
+	(*ObserverProcessor)(nil), // 3: com.orbservability.schema.v1.ObserverProcessor
+	(*ConfigReport)(nil),      // 4: com.orbservability.schema.v1.ConfigReport
+	(*PixieEvent)(nil),        // 5: com.orbservability.schema.v1.PixieEvent
+	(*emptypb.Empty)(nil),     // 6: google.protobuf.Empty
 }
 var file_com_orbservability_schema_v1_event_gateway_service_proto_depIdxs = []int32{
-	0, // 0: com.orbservability.schema.v1.EventGatewayService.StreamEvents:input_type -> com.orbservability.schema.v1.PixieEvent
-	1, // 1: com.orbservability.schema.v1.EventGatewayService.StreamEvents:output_type -> google.protobuf.Empty
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	2, // 0: com.orbservability.schema.v1.ObserverConfig.scripts:type_name -> com.orbservability.schema.v1.ObserverScript
+	3, // 1: com.orbservability.schema.v1.ObserverConfig.processors:type_name -> com.orbservability.schema.v1.ObserverProcessor
+	0, // 2: com.orbservability.schema.v1.ConfigReport.identity:type_name -> com.orbservability.schema.v1.ObserverIdentity
+	5, // 3: com.orbservability.schema.v1.EventGatewayService.StreamEvents:input_type -> com.orbservability.schema.v1.PixieEvent
+	0, // 4: com.orbservability.schema.v1.EventGatewayService.WatchConfig:input_type -> com.orbservability.schema.v1.ObserverIdentity
+	4, // 5: com.orbservability.schema.v1.EventGatewayService.ReportConfig:input_type -> com.orbservability.schema.v1.ConfigReport
+	6, // 6: com.orbservability.schema.v1.EventGatewayService.StreamEvents:output_type -> google.protobuf.Empty
+	1, // 7: com.orbservability.schema.v1.EventGatewayService.WatchConfig:output_type -> com.orbservability.schema.v1.ObserverConfig
+	6, // 8: com.orbservability.schema.v1.EventGatewayService.ReportConfig:output_type -> google.protobuf.Empty
+	6, // [6:9] is the sub-list for method output_type
+	3, // [3:6] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_com_orbservability_schema_v1_event_gateway_service_proto_init() }
@@ -65,18 +508,81 @@ func file_com_orbservability_schema_v1_event_gateway_service_proto_init() {
 		return
 	}
 	file_com_orbservability_schema_v1_pixie_event_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ObserverIdentity); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ObserverConfig); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ObserverScript); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ObserverProcessor); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConfigReport); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_com_orbservability_schema_v1_event_gateway_service_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   0,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_com_orbservability_schema_v1_event_gateway_service_proto_goTypes,
 		DependencyIndexes: file_com_orbservability_schema_v1_event_gateway_service_proto_depIdxs,
+		MessageInfos:      file_com_orbservability_schema_v1_event_gateway_service_proto_msgTypes,
 	}.Build()
 	File_com_orbservability_schema_v1_event_gateway_service_proto = out.File
 	file_com_orbservability_schema_v1_event_gateway_service_proto_rawDesc = nil
@@ -21,6 +21,8 @@ const _ = grpc.SupportPackageIsVersion7
 
 const (
 	EventGatewayService_StreamEvents_FullMethodName = "/com.orbservability.schema.v1.EventGatewayService/StreamEvents"
+	EventGatewayService_WatchConfig_FullMethodName  = "/com.orbservability.schema.v1.EventGatewayService/WatchConfig"
+	EventGatewayService_ReportConfig_FullMethodName = "/com.orbservability.schema.v1.EventGatewayService/ReportConfig"
 )
 
 // EventGatewayServiceClient is the client API for EventGatewayService service.
@@ -28,6 +30,10 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type EventGatewayServiceClient interface {
 	StreamEvents(ctx context.Context, opts ...grpc.CallOption) (EventGatewayService_StreamEventsClient, error)
+	// WatchConfig sends the observer's configuration, and again every time it changes.
+	WatchConfig(ctx context.Context, in *ObserverIdentity, opts ...grpc.CallOption) (EventGatewayService_WatchConfigClient, error)
+	// ReportConfig tells the gateway whether the observer applied a configuration.
+	ReportConfig(ctx context.Context, in *ConfigReport, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
 type eventGatewayServiceClient struct {
@@ -72,11 +78,56 @@ func (x *eventGatewayServiceStreamEventsClient) CloseAndRecv() (*emptypb.Empty,
 	return m, nil
 }
 
+func (c *eventGatewayServiceClient) WatchConfig(ctx context.Context, in *ObserverIdentity, opts ...grpc.CallOption) (EventGatewayService_WatchConfigClient, error) {
+	stream, err := c.cc.NewStream(ctx, &EventGatewayService_ServiceDesc.Streams[1], EventGatewayService_WatchConfig_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventGatewayServiceWatchConfigClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type EventGatewayService_WatchConfigClient interface {
+	Recv() (*ObserverConfig, error)
+	grpc.ClientStream
+}
+
+type eventGatewayServiceWatchConfigClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventGatewayServiceWatchConfigClient) Recv() (*ObserverConfig, error) {
+	m := new(ObserverConfig)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *eventGatewayServiceClient) ReportConfig(ctx context.Context, in *ConfigReport, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, EventGatewayService_ReportConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // EventGatewayServiceServer is the server API for EventGatewayService service.
 // All implementations must embed UnimplementedEventGatewayServiceServer
 // for forward compatibility
 type EventGatewayServiceServer interface {
 	StreamEvents(EventGatewayService_StreamEventsServer) error
+	// WatchConfig sends the observer's configuration, and again every time it changes.
+	WatchConfig(*ObserverIdentity, EventGatewayService_WatchConfigServer) error
+	// ReportConfig tells the gateway whether the observer applied a configuration.
+	ReportConfig(context.Context, *ConfigReport) (*emptypb.Empty, error)
 	mustEmbedUnimplementedEventGatewayServiceServer()
 }
 
@@ -87,6 +138,12 @@ type UnimplementedEventGatewayServiceServer struct {
 func (UnimplementedEventGatewayServiceServer) StreamEvents(EventGatewayService_StreamEventsServer) error {
 	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
 }
+func (UnimplementedEventGatewayServiceServer) WatchConfig(*ObserverIdentity, EventGatewayService_WatchConfigServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchConfig not implemented")
+}
+func (UnimplementedEventGatewayServiceServer) ReportConfig(context.Context, *ConfigReport) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportConfig not implemented")
+}
 func (UnimplementedEventGatewayServiceServer) mustEmbedUnimplementedEventGatewayServiceServer() {}
 
 // UnsafeEventGatewayServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -126,19 +183,68 @@ func (x *eventGatewayServiceStreamEventsServer) Recv() (*PixieEvent, error) {
 	return m, nil
 }
 
+func _EventGatewayService_WatchConfig_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ObserverIdentity)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventGatewayServiceServer).WatchConfig(m, &eventGatewayServiceWatchConfigServer{stream})
+}
+
+type EventGatewayService_WatchConfigServer interface {
+	Send(*ObserverConfig) error
+	grpc.ServerStream
+}
+
+type eventGatewayServiceWatchConfigServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventGatewayServiceWatchConfigServer) Send(m *ObserverConfig) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _EventGatewayService_ReportConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventGatewayServiceServer).ReportConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventGatewayService_ReportConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventGatewayServiceServer).ReportConfig(ctx, req.(*ConfigReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // EventGatewayService_ServiceDesc is the grpc.ServiceDesc for EventGatewayService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var EventGatewayService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "com.orbservability.schema.v1.EventGatewayService",
 	HandlerType: (*EventGatewayServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReportConfig",
+			Handler:    _EventGatewayService_ReportConfig_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "StreamEvents",
 			Handler:       _EventGatewayService_StreamEvents_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "WatchConfig",
+			Handler:       _EventGatewayService_WatchConfig_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "com/orbservability/schema/v1/event_gateway_service.proto",
 }
@@ -0,0 +1,355 @@
+package pixie
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"orbservability/observer/pkg/processor"
+	"orbservability/observer/pkg/sink"
+
+	"github.com/rs/zerolog/log"
+	"px.dev/pxapi"
+	"px.dev/pxapi/proto/vizierpb"
+	"px.dev/pxapi/types"
+)
+
+// A capture file holds the tables of script executions as JSON lines, one
+// per call made by pxapi to the table muxer and its handlers, in the order
+// they were made. Values are encoded by column type: booleans, numbers and
+// strings as such, times as Unix nanoseconds and 128 bit integers as
+// [high, low].
+const (
+	captureAccept = "accept" // AcceptTable, with the table's metadata
+	captureInit   = "init"   // HandleInit, with the table's metadata
+	captureRecord = "record" // HandleRecord, with the record's values
+	captureDone   = "done"   // HandleDone
+)
+
+// captureEntry is a line of a capture file.
+type captureEntry struct {
+	At       time.Time         `json:"at"`
+	Source   string            `json:"source"`
+	Script   string            `json:"script"`
+	Table    int64             `json:"table"` // Distinguishes the tables of a capture
+	Call     string            `json:"call"`
+	Metadata *captureMetadata  `json:"metadata,omitempty"`
+	Values   []json.RawMessage `json:"values,omitempty"`
+}
+
+type captureMetadata struct {
+	Name    string          `json:"name"`
+	Columns []captureColumn `json:"columns"`
+}
+
+type captureColumn struct {
+	Name         string                `json:"name"`
+	Type         vizierpb.DataType     `json:"type"`
+	SemanticType vizierpb.SemanticType `json:"semantic_type"`
+}
+
+// Recorder writes the tables of script executions to a capture file, in
+// place of handling them. Recorder is safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	tables int64
+	err    error // First write error, returned by Close
+}
+
+// NewRecorder creates the capture file at path, which must not exist. Only
+// the owner can read it, since captured records hold request and response
+// bodies.
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// Muxer returns the table muxer recording the tables of script executed against source.
+func (r *Recorder) Muxer(source string, script string) pxapi.TableMuxer {
+	return &recordingMux{recorder: r, source: source, script: script}
+}
+
+// Close flushes the capture file and closes it.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.err
+	if flushErr := r.w.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := r.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (r *Recorder) write(entry captureEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.w.Write(line)
+	if err := r.w.WriteByte('\n'); err != nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// Satisfies the TableMuxer interface, recording every table it accepts.
+type recordingMux struct {
+	recorder *Recorder
+	source   string
+	script   string
+}
+
+func (m *recordingMux) AcceptTable(ctx context.Context, metadata types.TableMetadata) (pxapi.TableRecordHandler, error) {
+	m.recorder.mu.Lock()
+	m.recorder.tables++
+	table := m.recorder.tables
+	m.recorder.mu.Unlock()
+
+	h := &recordingHandler{recorder: m.recorder, source: m.source, script: m.script, table: table}
+	if err := h.write(captureAccept, captureMetadataOf(metadata), nil); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Satisfies the TableRecordHandler interface, recording every call.
+type recordingHandler struct {
+	recorder *Recorder
+	source   string
+	script   string
+	table    int64
+}
+
+func (h *recordingHandler) HandleInit(ctx context.Context, metadata types.TableMetadata) error {
+	return h.write(captureInit, captureMetadataOf(metadata), nil)
+}
+
+func (h *recordingHandler) HandleRecord(ctx context.Context, r *types.Record) error {
+	values := make([]json.RawMessage, len(r.Data))
+	for i, d := range r.Data {
+		value, err := json.Marshal(datumValue(d))
+		if err != nil {
+			return err
+		}
+		values[i] = value
+	}
+	return h.write(captureRecord, nil, values)
+}
+
+func (h *recordingHandler) HandleDone(ctx context.Context) error {
+	return h.write(captureDone, nil, nil)
+}
+
+func (h *recordingHandler) write(call string, metadata *captureMetadata, values []json.RawMessage) error {
+	return h.recorder.write(captureEntry{
+		At:       time.Now(),
+		Source:   h.source,
+		Script:   h.script,
+		Table:    h.table,
+		Call:     call,
+		Metadata: metadata,
+		Values:   values,
+	})
+}
+
+func captureMetadataOf(metadata types.TableMetadata) *captureMetadata {
+	m := &captureMetadata{Name: metadata.Name, Columns: make([]captureColumn, len(metadata.ColInfo))}
+	for i, col := range metadata.ColInfo {
+		m.Columns[i] = captureColumn{Name: col.Name, Type: col.Type, SemanticType: col.SemanticType}
+	}
+	return m
+}
+
+// tableMetadata rebuilds the metadata of a captured table.
+func (m *captureMetadata) tableMetadata() types.TableMetadata {
+	metadata := types.TableMetadata{
+		Name:         m.Name,
+		ColInfo:      make([]types.ColSchema, len(m.Columns)),
+		ColIdxByName: make(map[string]int64, len(m.Columns)),
+	}
+	for i, col := range m.Columns {
+		metadata.ColInfo[i] = types.ColSchema{Name: col.Name, Type: col.Type, SemanticType: col.SemanticType}
+		metadata.ColIdxByName[col.Name] = int64(i)
+	}
+	return metadata
+}
+
+// datumValue returns the value a datum is captured as.
+func datumValue(d types.Datum) any {
+	switch v := d.(type) {
+	case *types.BooleanValue:
+		return v.Value()
+	case *types.Int64Value:
+		return v.Value()
+	case *types.Float64Value:
+		return v.Value()
+	case *types.StringValue:
+		return v.Value()
+	case *types.Time64NSValue:
+		return v.Value().UnixNano()
+	case *types.UInt128Value:
+		return [2]uint64{v.Value().GetHigh(), v.Value().GetLow()}
+	default:
+		return d.String()
+	}
+}
+
+// datum rebuilds a captured value of a column.
+func datum(col *types.ColSchema, value json.RawMessage) (types.Datum, error) {
+	var err error
+	switch col.Type {
+	case vizierpb.BOOLEAN:
+		var v bool
+		err = json.Unmarshal(value, &v)
+		d := types.NewBooleanValue(col)
+		d.ScanBool(v)
+		return d, err
+	case vizierpb.INT64:
+		var v int64
+		err = json.Unmarshal(value, &v)
+		d := types.NewInt64Value(col)
+		d.ScanInt64(v)
+		return d, err
+	case vizierpb.FLOAT64:
+		var v float64
+		err = json.Unmarshal(value, &v)
+		d := types.NewFloat64Value(col)
+		d.ScanFloat64(v)
+		return d, err
+	case vizierpb.STRING:
+		var v string
+		err = json.Unmarshal(value, &v)
+		d := types.NewStringValue(col)
+		d.ScanString(v)
+		return d, err
+	case vizierpb.TIME64NS:
+		var v int64
+		err = json.Unmarshal(value, &v)
+		d := types.NewTime64NSValue(col)
+		d.ScanInt64(v)
+		return d, err
+	case vizierpb.UINT128:
+		var v [2]uint64
+		err = json.Unmarshal(value, &v)
+		d := types.NewUint128Value(col)
+		d.ScanUInt128(&vizierpb.UInt128{High: v[0], Low: v[1]})
+		return d, err
+	default:
+		return nil, fmt.Errorf("unsupported data type %d", col.Type)
+	}
+}
+
+// replayTable is a captured table being replayed.
+type replayTable struct {
+	metadata types.TableMetadata
+	handler  pxapi.TableRecordHandler
+}
+
+// Replay feeds the tables of the capture file at path through a TableMux
+// per table, as pxapi would have, handing the events to sink. Calls are
+// spaced as they were recorded, sped up by speed; a speed of 0 replays them
+// as fast as possible. Replay stops at the first error returned by a handler.
+func Replay(ctx context.Context, path string, speed float64, sink sink.Sink, processors processor.Processor) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tables := map[int64]*replayTable{}
+	var records int64
+	var last time.Time
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for line := 1; ; line++ {
+		var entry captureEntry
+		if err := dec.Decode(&entry); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+
+		if speed > 0 && !last.IsZero() {
+			if err := sleepContext(ctx, time.Duration(float64(entry.At.Sub(last))/speed)); err != nil {
+				return err
+			}
+		}
+		last = entry.At
+
+		if err := replay(ctx, tables, entry, sink, processors); err != nil {
+			return fmt.Errorf("%s:%d: %s of table %d: %w", path, line, entry.Call, entry.Table, err)
+		}
+		if entry.Call == captureRecord {
+			records++
+		}
+	}
+
+	log.Info().Str("capture", path).Int("tables", len(tables)).Int64("records", records).Msg("Capture replayed")
+	return nil
+}
+
+// replay makes the call of a single capture entry.
+func replay(ctx context.Context, tables map[int64]*replayTable, entry captureEntry, sink sink.Sink, processors processor.Processor) error {
+	table, found := tables[entry.Table]
+	if entry.Call == captureAccept {
+		if found || entry.Metadata == nil {
+			return errors.New("invalid capture")
+		}
+		table = &replayTable{metadata: entry.Metadata.tableMetadata()}
+		tm := &TableMux{Sink: sink, Source: entry.Source, Processors: processors}
+		handler, err := tm.AcceptTable(ctx, table.metadata)
+		if err != nil {
+			return err
+		}
+		table.handler = handler
+		tables[entry.Table] = table
+		return nil
+	}
+	if !found {
+		return errors.New("table not accepted")
+	}
+
+	switch entry.Call {
+	case captureInit:
+		if entry.Metadata == nil {
+			return errors.New("invalid capture")
+		}
+		table.metadata = entry.Metadata.tableMetadata()
+		return table.handler.HandleInit(ctx, table.metadata)
+	case captureRecord:
+		r := &types.Record{Data: make([]types.Datum, len(entry.Values)), TableMetadata: &table.metadata}
+		for i, value := range entry.Values {
+			if i >= len(table.metadata.ColInfo) {
+				return fmt.Errorf("%d values for %d columns", len(entry.Values), len(table.metadata.ColInfo))
+			}
+			d, err := datum(&table.metadata.ColInfo[i], value)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", table.metadata.ColInfo[i].Name, err)
+			}
+			r.Data[i] = d
+		}
+		return table.handler.HandleRecord(ctx, r)
+	case captureDone:
+		return table.handler.HandleDone(ctx)
+	default:
+		return fmt.Errorf("unknown call %q", entry.Call)
+	}
+}
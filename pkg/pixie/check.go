@@ -0,0 +1,118 @@
+package pixie
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+
+	"orbservability/observer/pkg/config"
+
+	"px.dev/pxapi"
+	"px.dev/pxapi/types"
+)
+
+// TableSummary describes a table returned by a script execution.
+type TableSummary struct {
+	Metadata types.TableMetadata
+	Records  int64
+}
+
+// CheckScript executes script against source once, returning the tables it
+// returned before it completed or ctx was done, for a streaming script. Unlike
+// the daemon, which retries them, any execution error is returned.
+func CheckScript(ctx context.Context, source *Source, script config.Script) ([]TableSummary, error) {
+	client, err := source.connect()
+	if err != nil {
+		return nil, err
+	}
+	vz, err := client.NewVizierClient(ctx, source.Config.VizierHost)
+	if err != nil {
+		return nil, err
+	}
+
+	tm := &summaryMux{}
+	resultSet, err := vz.ExecuteScript(ctx, script.PxL, tm)
+	if err != nil {
+		return nil, err
+	}
+	defer resultSet.Close()
+
+	for {
+		err := resultSet.Stream()
+		if err == nil {
+			continue
+		}
+		if err == io.EOF || ctx.Err() != nil {
+			return tm.summaries(), nil
+		}
+		return tm.summaries(), err
+	}
+}
+
+// Ping checks that the PEM of a source accepts connections, completing a TLS
+// handshake when TLS is enabled.
+func Ping(ctx context.Context, source config.PixieSource) error {
+	if source.TLS.Enabled {
+		tlsConfig, err := source.TLS.ClientConfig(source.URL)
+		if err != nil {
+			return err
+		}
+		return verifyTLS(ctx, source.URL, tlsConfig)
+	}
+
+	dialer := &net.Dialer{Timeout: tlsHandshakeTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", source.URL)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Satisfies the TableMuxer interface, counting the records of every table.
+type summaryMux struct {
+	mu     sync.Mutex
+	tables []*TableSummary
+}
+
+func (m *summaryMux) AcceptTable(ctx context.Context, metadata types.TableMetadata) (pxapi.TableRecordHandler, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	table := &TableSummary{Metadata: metadata}
+	m.tables = append(m.tables, table)
+	return &summaryHandler{mux: m, table: table}, nil
+}
+
+func (m *summaryMux) summaries() []TableSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	summaries := make([]TableSummary, len(m.tables))
+	for i, table := range m.tables {
+		summaries[i] = *table
+	}
+	return summaries
+}
+
+// Satisfies the TableRecordHandler interface.
+type summaryHandler struct {
+	mux   *summaryMux
+	table *TableSummary
+}
+
+func (h *summaryHandler) HandleInit(ctx context.Context, metadata types.TableMetadata) error {
+	h.mux.mu.Lock()
+	defer h.mux.mu.Unlock()
+	h.table.Metadata = metadata
+	return nil
+}
+
+func (h *summaryHandler) HandleRecord(ctx context.Context, r *types.Record) error {
+	h.mux.mu.Lock()
+	defer h.mux.mu.Unlock()
+	h.table.Records++
+	return nil
+}
+
+func (h *summaryHandler) HandleDone(ctx context.Context) error {
+	return nil
+}
@@ -2,15 +2,49 @@ package pixie
 
 import (
 	"context"
+	"errors"
 	"orbservability/observer/pkg/config"
 
 	"px.dev/pxapi"
 )
 
-func CreateClient(ctx context.Context, cfg *config.Config) (*pxapi.Client, error) {
+// CreateClient connects to the standalone PEM of a single source.
+// When TLS is enabled the certificate chain is verified up front and the
+// connection is tunnelled through a TLS bridge on a private Unix socket.
+func CreateClient(ctx context.Context, source config.PixieSource) (*pxapi.Client, error) {
+	addr := source.URL
+
+	if source.TLS.Enabled {
+		tlsConfig, err := source.TLS.ClientConfig(source.URL)
+		if err != nil {
+			return nil, misconfiguredError{err}
+		}
+		if err := verifyTLS(ctx, source.URL, tlsConfig); err != nil {
+			return nil, err
+		}
+		bridge, err := newTLSBridge(ctx, source.URL, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		addr = bridge.Addr()
+	}
+
 	return pxapi.NewClient(
 		ctx,
-		pxapi.WithDirectAddr(cfg.PixieURL),
+		pxapi.WithDirectAddr(addr),
 		pxapi.WithDirectCredsInsecure(),
 	)
 }
+
+// misconfiguredError wraps a connection error that retrying cannot fix.
+type misconfiguredError struct{ error }
+
+func (e misconfiguredError) Unwrap() error { return e.error }
+
+// isMisconfigured reports whether err is due to the configuration of a
+// source, such as TLS files that cannot be loaded or a certificate chain that
+// does not validate, rather than the source being unreachable.
+func isMisconfigured(err error) bool {
+	var misconfigured misconfiguredError
+	return errors.As(err, &misconfigured)
+}
@@ -0,0 +1,111 @@
+package pixie
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"px.dev/pxapi"
+	"px.dev/pxapi/proto/vizierpb"
+)
+
+// vizier is the part of *pxapi.VizierClient needed to execute scripts.
+type vizier interface {
+	ExecuteScript(ctx context.Context, pxl string, mux pxapi.TableMuxer) (scriptResults, error)
+}
+
+// scriptResults is the part of *pxapi.ScriptResults needed to stream an execution.
+type scriptResults interface {
+	Stream() error
+	Close() error
+	Stats() *vizierpb.QueryExecutionStats
+}
+
+// vizierClient adapts *pxapi.VizierClient to vizier.
+type vizierClient struct {
+	*pxapi.VizierClient
+}
+
+func (c vizierClient) ExecuteScript(ctx context.Context, pxl string, mux pxapi.TableMuxer) (scriptResults, error) {
+	resultSet, err := c.VizierClient.ExecuteScript(ctx, pxl, mux)
+	if err != nil {
+		return nil, err // Not a nil *pxapi.ScriptResults in a non-nil interface
+	}
+	return resultSet, nil
+}
+
+// execution is a single run of a PxL script. Its lifecycle is explicit:
+// startExecution, stream, close and wait. Every started execution must be
+// closed, which cancels its context, releases the result set and lets the
+// watchdog goroutine exit.
+type execution struct {
+	started   time.Time
+	resultSet scriptResults
+	mux       *executionMux
+	watchdog  *watchdog
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// startExecution executes pxl against vz. idleTimeout enables the stalled
+// stream watchdog when positive.
+func startExecution(ctx context.Context, vz vizier, pxl string, tm pxapi.TableMuxer, idleTimeout time.Duration) (*execution, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	e := &execution{started: time.Now(), cancel: cancel}
+	if idleTimeout > 0 {
+		e.watchdog = newWatchdog(idleTimeout)
+	}
+	e.mux = &executionMux{TableMuxer: tm, watchdog: e.watchdog}
+
+	resultSet, err := vz.ExecuteScript(ctx, pxl, e.mux)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	e.resultSet = resultSet
+
+	if e.watchdog != nil {
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			e.watchdog.watch(ctx, cancel)
+		}()
+	}
+
+	return e, nil
+}
+
+// stream blocks until the result set is exhausted, closed or cancelled.
+func (e *execution) stream() error {
+	return streamResults(e.resultSet)
+}
+
+// close cancels the execution and releases its result set. It is safe to call more than once.
+func (e *execution) close() {
+	e.closeOnce.Do(func() {
+		e.cancel()
+		e.resultSet.Close()
+	})
+}
+
+// wait blocks until every goroutine owned by the execution has exited.
+func (e *execution) wait() {
+	e.wg.Wait()
+}
+
+// stalled reports whether the watchdog cancelled the execution.
+func (e *execution) stalled() bool {
+	return e.watchdog != nil && e.watchdog.stalled.Load()
+}
+
+// tables returns the number of tables the execution produced.
+func (e *execution) tables() int64 {
+	return e.mux.tables.Load()
+}
+
+// records returns the number of records the execution produced.
+func (e *execution) records() int64 {
+	return e.mux.records.Load()
+}
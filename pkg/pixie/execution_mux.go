@@ -0,0 +1,50 @@
+package pixie
+
+import (
+	"context"
+	"sync/atomic"
+
+	"px.dev/pxapi"
+	"px.dev/pxapi/types"
+)
+
+// Satisfies the TableMuxer interface, tracking the tables and activity of a
+// single script execution.
+type executionMux struct {
+	pxapi.TableMuxer
+	watchdog *watchdog // nil when the idle timeout is disabled
+	tables   atomic.Int64
+	records  atomic.Int64
+}
+
+func (m *executionMux) AcceptTable(ctx context.Context, metadata types.TableMetadata) (pxapi.TableRecordHandler, error) {
+	m.watchdog.touch()
+	m.tables.Add(1)
+	handler, err := m.TableMuxer.AcceptTable(ctx, metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &executionHandler{TableRecordHandler: handler, mux: m}, nil
+}
+
+// Satisfies the TableRecordHandler interface, recording activity on the watchdog.
+type executionHandler struct {
+	pxapi.TableRecordHandler
+	mux *executionMux
+}
+
+func (h *executionHandler) HandleInit(ctx context.Context, metadata types.TableMetadata) error {
+	h.mux.watchdog.touch()
+	return h.TableRecordHandler.HandleInit(ctx, metadata)
+}
+
+func (h *executionHandler) HandleRecord(ctx context.Context, r *types.Record) error {
+	h.mux.watchdog.touch()
+	h.mux.records.Add(1)
+	return h.TableRecordHandler.HandleRecord(ctx, r)
+}
+
+func (h *executionHandler) HandleDone(ctx context.Context) error {
+	h.mux.watchdog.touch()
+	return h.TableRecordHandler.HandleDone(ctx)
+}
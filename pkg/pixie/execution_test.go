@@ -0,0 +1,239 @@
+package pixie
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"orbservability/observer/pkg/config"
+
+	"px.dev/pxapi"
+	"px.dev/pxapi/proto/vizierpb"
+	"px.dev/pxapi/types"
+)
+
+// fakeVizier executes scripts that return a single table of one record.
+// Like pxapi, every execution owns a goroutine that exits once its context is
+// done, so an execution that is never cancelled shows up as a leak.
+type fakeVizier struct {
+	t        *testing.T
+	stall    bool             // Block after the first record until cancelled
+	fail     func(n int) bool // Whether execution n fails to start
+	executed func(n int)      // Called before execution n starts
+
+	executions int
+	open       atomic.Int64 // Result sets not closed yet
+	wg         sync.WaitGroup
+}
+
+func (v *fakeVizier) ExecuteScript(ctx context.Context, pxl string, mux pxapi.TableMuxer) (scriptResults, error) {
+	v.executions++
+	if open := v.open.Load(); open != 0 {
+		v.t.Fatalf("execution %d started with %d result set(s) still open", v.executions, open)
+	}
+	if v.executed != nil {
+		v.executed(v.executions)
+	}
+	if v.fail != nil && v.fail(v.executions) {
+		return nil, errors.New("vizier unavailable")
+	}
+
+	v.open.Add(1)
+	v.wg.Add(1)
+	go func() {
+		defer v.wg.Done()
+		<-ctx.Done()
+	}()
+	return &fakeResults{vizier: v, ctx: ctx, mux: mux}, nil
+}
+
+type fakeResults struct {
+	vizier   *fakeVizier
+	ctx      context.Context
+	mux      pxapi.TableMuxer
+	streamed bool
+	closed   bool
+}
+
+func (r *fakeResults) Stream() error {
+	if r.streamed {
+		return io.EOF
+	}
+	r.streamed = true
+
+	metadata := types.TableMetadata{Name: "http_events"}
+	handler, err := r.mux.AcceptTable(r.ctx, metadata)
+	if err != nil {
+		return err
+	}
+	if err := handler.HandleInit(r.ctx, metadata); err != nil {
+		return err
+	}
+	if err := handler.HandleRecord(r.ctx, &types.Record{TableMetadata: &metadata}); err != nil {
+		return err
+	}
+	if r.vizier.stall {
+		<-r.ctx.Done()
+		return r.ctx.Err()
+	}
+	if err := handler.HandleDone(r.ctx); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+func (r *fakeResults) Close() error {
+	if !r.closed {
+		r.closed = true
+		r.vizier.open.Add(-1)
+	}
+	return nil
+}
+
+func (r *fakeResults) Stats() *vizierpb.QueryExecutionStats {
+	return nil
+}
+
+type nopMux struct{}
+
+func (nopMux) AcceptTable(ctx context.Context, metadata types.TableMetadata) (pxapi.TableRecordHandler, error) {
+	return nopHandler{}, nil
+}
+
+type nopHandler struct{}
+
+func (nopHandler) HandleInit(ctx context.Context, metadata types.TableMetadata) error { return nil }
+func (nopHandler) HandleRecord(ctx context.Context, r *types.Record) error            { return nil }
+func (nopHandler) HandleDone(ctx context.Context) error                               { return nil }
+
+func testExecutionConfig(idleTimeout time.Duration, maxErrorCount int) *config.Config {
+	return &config.Config{Execution: config.ExecutionConfig{
+		IdleTimeout:   config.Duration(idleTimeout),
+		MaxErrorCount: maxErrorCount,
+		Backoff:       config.BackoffConfig{Base: config.Duration(time.Microsecond), Max: config.Duration(time.Millisecond)},
+	}}
+}
+
+// checkGoroutines fails the test when the number of goroutines stays above
+// baseline, allowing the goroutines of closed executions time to exit.
+func checkGoroutines(t *testing.T, n int, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		goroutines := runtime.NumGoroutine()
+		if goroutines <= baseline {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after %d executions, %d after warming up", goroutines, n, baseline)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRunExecutionsReleasesEveryExecution(t *testing.T) {
+	const executions = 5000
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	baseline := 0
+	vz := &fakeVizier{t: t}
+	vz.executed = func(n int) {
+		switch {
+		case n == 100:
+			vz.wg.Wait() // Every execution so far is closed
+			baseline = runtime.NumGoroutine()
+		case n > 100 && n%500 == 0:
+			checkGoroutines(t, n, baseline)
+		}
+		if n == executions {
+			cancel()
+		}
+	}
+
+	source := &Source{Config: config.PixieSource{Name: "test"}}
+	err := runExecutions(ctx, vz, source, config.Script{Name: "test"}, testExecutionConfig(time.Minute, 0), nopMux{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("runExecutions returned %v, want context.Canceled", err)
+	}
+	vz.wg.Wait()
+	if open := vz.open.Load(); open != 0 {
+		t.Fatalf("%d result set(s) left open", open)
+	}
+	if vz.executions != executions {
+		t.Fatalf("%d executions, want %d", vz.executions, executions)
+	}
+}
+
+func TestRunExecutionsReleasesStalledExecutions(t *testing.T) {
+	const stalls = 8
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	baseline := 0
+	vz := &fakeVizier{t: t, stall: true}
+	vz.executed = func(n int) {
+		switch {
+		case n == 2:
+			vz.wg.Wait()
+			baseline = runtime.NumGoroutine()
+		case n > 2:
+			checkGoroutines(t, n, baseline)
+		}
+		if n > stalls {
+			cancel()
+		}
+	}
+
+	source := &Source{Config: config.PixieSource{Name: "test"}}
+	err := runExecutions(ctx, vz, source, config.Script{Name: "test"}, testExecutionConfig(10*time.Millisecond, 0), nopMux{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("runExecutions returned %v, want context.Canceled", err)
+	}
+	vz.wg.Wait()
+	if open := vz.open.Load(); open != 0 {
+		t.Fatalf("%d result set(s) left open", open)
+	}
+	if got := source.Stalls(); got != stalls {
+		t.Fatalf("%d stalls, want %d", got, stalls)
+	}
+}
+
+func TestRunExecutionsResetsErrorCountAfterSuccess(t *testing.T) {
+	const executions = 1000
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	vz := &fakeVizier{t: t}
+	vz.fail = func(n int) bool { return n%2 == 1 }
+	vz.executed = func(n int) {
+		if n == executions {
+			cancel()
+		}
+	}
+
+	source := &Source{Config: config.PixieSource{Name: "test"}}
+	err := runExecutions(ctx, vz, source, config.Script{Name: "test"}, testExecutionConfig(time.Minute, 1), nopMux{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("runExecutions returned %v after %d executions, want context.Canceled", err, vz.executions)
+	}
+}
+
+func TestRunExecutionsGivesUpAfterFailuresInARow(t *testing.T) {
+	vz := &fakeVizier{t: t}
+	vz.fail = func(n int) bool { return n > 1 }
+
+	source := &Source{Config: config.PixieSource{Name: "test"}}
+	err := runExecutions(context.Background(), vz, source, config.Script{Name: "test"}, testExecutionConfig(time.Minute, 3), nopMux{})
+	if err == nil || errors.Is(err, context.Canceled) {
+		t.Fatalf("runExecutions returned %v, want the execution error", err)
+	}
+	if vz.executions != 5 {
+		t.Fatalf("%d executions, want 1 successful and 4 failed", vz.executions)
+	}
+}
@@ -0,0 +1,83 @@
+package pixie
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	scriptExecutions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "observer_pixie_script_executions_total",
+		Help: "Completed PxL script executions.",
+	}, []string{"source", "script"})
+
+	scriptRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "observer_pixie_records_processed_total",
+		Help: "Records processed by the PEM while executing PxL scripts.",
+	}, []string{"source", "script"})
+
+	scriptBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "observer_pixie_bytes_processed_total",
+		Help: "Bytes processed by the PEM while executing PxL scripts.",
+	}, []string{"source", "script"})
+
+	scriptExecutionTime = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "observer_pixie_execution_seconds",
+		Help:    "PxL script execution time reported by the PEM.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"source", "script"})
+
+	scriptCompilationTime = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "observer_pixie_compilation_seconds",
+		Help:    "PxL script compilation time reported by the PEM.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"source", "script"})
+
+	scriptTables = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "observer_pixie_tables",
+		Help: "Tables returned by the last PxL script execution.",
+	}, []string{"source", "script"})
+
+	streamStalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "observer_pixie_stream_stalls_total",
+		Help: "Script executions cancelled by the idle watchdog.",
+	}, []string{"source", "script"})
+)
+
+// recordStats publishes the statistics of a completed script execution.
+func recordStats(source string, script string, tables int64, resultSet scriptResults) {
+	stats := resultSet.Stats()
+	records := stats.GetRecordsProcessed()
+	bytes := stats.GetBytesProcessed()
+	execution := time.Duration(stats.GetTiming().GetExecutionTimeNs())
+	compilation := time.Duration(stats.GetTiming().GetCompilationTimeNs())
+
+	scriptExecutions.WithLabelValues(source, script).Inc()
+	scriptRecords.WithLabelValues(source, script).Add(float64(records))
+	scriptBytes.WithLabelValues(source, script).Add(float64(bytes))
+	scriptExecutionTime.WithLabelValues(source, script).Observe(execution.Seconds())
+	scriptCompilationTime.WithLabelValues(source, script).Observe(compilation.Seconds())
+	scriptTables.WithLabelValues(source, script).Set(float64(tables))
+}
+
+// logStats logs the statistics of a completed script execution, along with
+// the tables and records it returned.
+func logStats(source string, script string, e *execution) {
+	event := log.Info().
+		Str("source", source).
+		Str("script", script).
+		Int64("tables", e.tables()).
+		Int64("records", e.records()).
+		Dur("duration", time.Since(e.started))
+	if stats := e.resultSet.Stats(); stats != nil {
+		event = event.
+			Int64("records_processed", stats.GetRecordsProcessed()).
+			Int64("bytes_processed", stats.GetBytesProcessed()).
+			Dur("execution_time", time.Duration(stats.GetTiming().GetExecutionTimeNs())).
+			Dur("compilation_time", time.Duration(stats.GetTiming().GetCompilationTimeNs()))
+	}
+	event.Msg("PxL script execution finished")
+}
@@ -0,0 +1,428 @@
+package pixie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/processor"
+	"orbservability/observer/pkg/sink"
+
+	"github.com/rs/zerolog/log"
+	"px.dev/pxapi"
+	"px.dev/pxapi/errdefs"
+	"px.dev/pxapi/types"
+)
+
+// compileTimeout bounds the execution Apply uses to check that a script compiles.
+const compileTimeout = 30 * time.Second
+
+// Source is a configured Pixie endpoint together with its client.
+type Source struct {
+	Config config.PixieSource
+
+	ctx    context.Context    // Lifetime of the client, including any TLS bridge
+	cancel context.CancelFunc // Releases the client
+	mu     sync.Mutex
+	client *pxapi.Client // Created by the first successful connect
+	stalls atomic.Int64  // Executions cancelled by the idle watchdog
+}
+
+func newSource(ctx context.Context, sc config.PixieSource) *Source {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Source{Config: sc, ctx: ctx, cancel: cancel}
+}
+
+// connect returns the source's client, creating it on first use. A failed
+// attempt leaves the source unconnected, so that every worker streaming from
+// it retries with its own backoff.
+func (s *Source) connect() (*pxapi.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+	client, err := CreateClient(s.ctx, s.Config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return client, nil
+}
+
+// Stalls returns how many times the source's stream stalled and was re-executed.
+func (s *Source) Stalls() int64 {
+	return s.stalls.Load()
+}
+
+// Close releases the source's client.
+func (s *Source) Close() {
+	s.cancel()
+}
+
+// ConnectSources creates a source for every configured source and connects
+// them in parallel. A source that cannot be reached is logged and connected
+// later by its workers, so that it does not hold back the others; only a
+// misconfigured source, such as one whose certificate chain does not
+// validate, is returned as an error.
+func ConnectSources(ctx context.Context, cfg *config.Config) ([]*Source, error) {
+	sources := make([]*Source, len(cfg.Sources))
+	errs := make([]error, len(cfg.Sources))
+	var wg sync.WaitGroup
+	for i, sc := range cfg.Sources {
+		sources[i] = newSource(ctx, sc)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = connectSource(sources[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			for _, source := range sources {
+				source.Close()
+			}
+			return nil, fmt.Errorf("source %s: %w", cfg.Sources[i].Name, err)
+		}
+	}
+	return sources, nil
+}
+
+// connectSource makes a first attempt at connecting source, returning only
+// the errors that retrying cannot fix.
+func connectSource(source *Source) error {
+	_, err := source.connect()
+	if err == nil {
+		return nil
+	}
+	if isMisconfigured(err) {
+		return err
+	}
+	log.Warn().Err(err).Str("source", source.Config.Name).Msg("Pixie source cannot be reached, retrying in the background")
+	return nil
+}
+
+// StreamSources executes every PxL script against every source in parallel
+// and fans their events, filtered by processors, into sink.
+// A source that fails is logged and restarted without affecting the others;
+// only a script compilation error, which every source would hit, is returned.
+func StreamSources(ctx context.Context, sources []*Source, cfg *config.Config, sink sink.Sink, processors processor.Processor) error {
+	return NewSupervisor(sink, processors).Run(ctx, sources, cfg)
+}
+
+// Supervisor runs a worker per source and script, each streaming the script
+// from the source until it is stopped. Apply reconciles the workers with a new
+// configuration, leaving the workers it does not affect running.
+type Supervisor struct {
+	muxer   func(source string, script string) pxapi.TableMuxer // Handles the tables of a worker
+	connect func(ctx context.Context, source *Source) (vizier, error)
+	errs    chan error // Compilation errors of the scripts given to Start
+
+	mu      sync.Mutex
+	ctx     context.Context // Parent of every worker, done once Wait returns
+	cancel  context.CancelFunc
+	cfg     *config.Config
+	sources map[string]*Source
+	workers map[workerKey]*worker
+	wg      sync.WaitGroup
+}
+
+type workerKey struct {
+	source string
+	script string
+}
+
+type worker struct {
+	script config.Script
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSupervisor returns a supervisor handing the events of every worker,
+// filtered by processors, to sink, which must be safe for concurrent use.
+func NewSupervisor(sink sink.Sink, processors processor.Processor) *Supervisor {
+	return newSupervisor(func(source string, script string) pxapi.TableMuxer {
+		return &TableMux{Sink: sink, Source: source, Processors: processors}
+	})
+}
+
+// NewRecordingSupervisor returns a supervisor writing the tables of every
+// worker to recorder, rather than handling them.
+func NewRecordingSupervisor(recorder *Recorder) *Supervisor {
+	return newSupervisor(recorder.Muxer)
+}
+
+func newSupervisor(muxer func(source string, script string) pxapi.TableMuxer) *Supervisor {
+	return &Supervisor{
+		muxer:   muxer,
+		connect: connectVizier,
+		errs:    make(chan error, 1),
+		sources: map[string]*Source{},
+		workers: map[workerKey]*worker{},
+	}
+}
+
+// Run streams every script in cfg from sources until ctx is done or a script
+// fails to compile. The sources are closed when Run returns.
+func (s *Supervisor) Run(ctx context.Context, sources []*Source, cfg *config.Config) error {
+	s.Start(ctx, sources, cfg)
+	return s.Wait()
+}
+
+// Start starts streaming every script in cfg from sources, after which the
+// supervisor accepts Apply. Call Wait to stop.
+func (s *Supervisor) Start(ctx context.Context, sources []*Source, cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	s.cfg = cfg
+	for _, source := range sources {
+		s.sources[source.Config.Name] = source
+		for _, script := range cfg.Scripts {
+			s.start(source, script, true)
+		}
+	}
+}
+
+// Wait blocks until the ctx given to Start is done or one of the scripts given
+// to Start fails to compile, then stops every worker and closes the sources.
+func (s *Supervisor) Wait() error {
+	var err error
+	select {
+	case <-s.ctx.Done():
+	case err = <-s.errs:
+	}
+	s.cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wg.Wait()
+	for _, source := range s.sources {
+		source.Close()
+	}
+	return err
+}
+
+// Apply reconciles the running workers with cfg.
+// New and changed sources are connected before any worker is stopped, so a
+// misconfigured source rejects cfg and leaves everything running, while one
+// that cannot be reached yet is retried by its workers.
+// New and changed scripts are then executed once to check that they compile,
+// so that a script that does not rejects cfg too.
+// A worker is restarted only when its source, its script or the execution
+// settings changed.
+func (s *Supervisor) Apply(cfg *config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil || s.ctx.Err() != nil {
+		return errors.New("supervisor is not running")
+	}
+
+	connected := map[string]*Source{}
+	for _, sc := range cfg.Sources {
+		if current, ok := s.sources[sc.Name]; ok && current.Config == sc {
+			continue
+		}
+		source := newSource(s.ctx, sc)
+		if err := connectSource(source); err != nil {
+			source.Close()
+			for _, source := range connected {
+				source.Close()
+			}
+			return fmt.Errorf("source %s: %w", sc.Name, err)
+		}
+		connected[sc.Name] = source
+	}
+	if err := s.compile(cfg, connected); err != nil {
+		for _, source := range connected {
+			source.Close()
+		}
+		return err
+	}
+
+	sources := map[string]bool{}
+	for _, sc := range cfg.Sources {
+		sources[sc.Name] = true
+	}
+	scripts := map[string]config.Script{}
+	for _, script := range cfg.Scripts {
+		scripts[script.Name] = script
+	}
+	restartAll := s.cfg.Execution != cfg.Execution
+
+	var stale []workerKey
+	for key, w := range s.workers {
+		script, ok := scripts[key.script]
+		_, reconnected := connected[key.source]
+		if restartAll || reconnected || !ok || !sources[key.source] || script != w.script {
+			stale = append(stale, key)
+		}
+	}
+	s.stop(stale)
+
+	for name, source := range s.sources {
+		if _, reconnected := connected[name]; reconnected || !sources[name] {
+			source.Close()
+			delete(s.sources, name)
+		}
+	}
+	for name, source := range connected {
+		s.sources[name] = source
+	}
+
+	s.cfg = cfg
+	for _, sc := range cfg.Sources {
+		for _, script := range cfg.Scripts {
+			if _, ok := s.workers[workerKey{sc.Name, script.Name}]; !ok {
+				s.start(s.sources[sc.Name], script, false)
+			}
+		}
+	}
+	return nil
+}
+
+// compile checks that the scripts of cfg that are new or changed compile,
+// executing each once on the first source of cfg that can be reached. When
+// none can, the script is assumed to compile and its workers stop if it does
+// not. connected holds the sources Apply connected. s.mu must be held.
+func (s *Supervisor) compile(cfg *config.Config, connected map[string]*Source) error {
+	previous := map[string]config.Script{}
+	for _, script := range s.cfg.Scripts {
+		previous[script.Name] = script
+	}
+	for _, script := range cfg.Scripts {
+		if previous[script.Name] == script {
+			continue
+		}
+		for _, sc := range cfg.Sources {
+			source, ok := connected[sc.Name]
+			if !ok {
+				source = s.sources[sc.Name]
+			}
+			checked, err := s.checkCompiles(source, script)
+			if err != nil {
+				return fmt.Errorf("script %s: %w", script.Name, err)
+			}
+			if checked {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// checkCompiles executes script on source until its first table is returned,
+// returning the compilation error if there is one. checked is false when
+// source could not be reached to tell.
+func (s *Supervisor) checkCompiles(source *Source, script config.Script) (checked bool, err error) {
+	ctx, cancel := context.WithTimeout(s.ctx, compileTimeout)
+	defer cancel()
+
+	vz, err := s.connect(ctx, source)
+	if err != nil {
+		return false, nil
+	}
+	resultSet, err := vz.ExecuteScript(ctx, script.PxL, compiledMux{})
+	if err != nil {
+		if errdefs.IsCompilationError(err) {
+			return true, err
+		}
+		return false, nil
+	}
+	defer resultSet.Close()
+
+	if err := resultSet.Stream(); errdefs.IsCompilationError(err) {
+		return true, err
+	}
+	return true, nil
+}
+
+// errCompiled stops the execution of a script once it is known to compile.
+var errCompiled = errors.New("script compiled")
+
+// Satisfies the TableMuxer interface, rejecting the first table returned,
+// which is only returned once the script compiled.
+type compiledMux struct{}
+
+func (compiledMux) AcceptTable(ctx context.Context, metadata types.TableMetadata) (pxapi.TableRecordHandler, error) {
+	return nil, errCompiled
+}
+
+// start runs script against source in a new worker. When fatal is set, a
+// compilation error stops the supervisor, as the scripts given to Start are
+// not checked beforehand. Otherwise it is logged and only the worker stops.
+// s.mu must be held.
+func (s *Supervisor) start(source *Source, script config.Script, fatal bool) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	w := &worker{script: script, cancel: cancel, done: make(chan struct{})}
+	s.workers[workerKey{source.Config.Name, script.Name}] = w
+
+	cfg := s.cfg
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(w.done)
+		defer cancel()
+
+		tm := s.muxer(source.Config.Name, script.Name)
+		err := streamSource(ctx, s.connect, source, script, cfg, tm)
+		if err == nil {
+			return
+		}
+		if !fatal {
+			log.Error().Err(err).Str("source", source.Config.Name).Str("script", script.Name).Msg("Script does not compile, stopped streaming it until it changes")
+			return
+		}
+		select {
+		case s.errs <- fmt.Errorf("source %s, script %s: %w", source.Config.Name, script.Name, err):
+		default:
+		}
+	}()
+}
+
+// stop cancels the workers and waits for them to finish. s.mu must be held.
+func (s *Supervisor) stop(keys []workerKey) {
+	for _, key := range keys {
+		s.workers[key].cancel()
+	}
+	for _, key := range keys {
+		<-s.workers[key].done
+		delete(s.workers, key)
+	}
+}
+
+func streamSource(ctx context.Context, connect func(ctx context.Context, source *Source) (vizier, error), source *Source, script config.Script, cfg *config.Config, tm pxapi.TableMuxer) error {
+	restarts := 0
+	for {
+		started := time.Now()
+		vz, err := connect(ctx, source)
+		if err == nil {
+			err = runExecutions(ctx, vz, source, script, cfg, tm)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errdefs.IsCompilationError(err) {
+			return err
+		}
+
+		// A source that streamed for a while before failing starts backing off afresh
+		if time.Since(started) > cfg.Execution.Backoff.Max.Duration() {
+			restarts = 0
+		}
+		restarts++
+		delay := cfg.Execution.Backoff.Delay(restarts)
+		log.Error().Err(err).Str("source", source.Config.Name).Str("script", script.Name).Dur("retry_in", delay).Msg("Pixie source stopped streaming, restarting")
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil
+		}
+	}
+}
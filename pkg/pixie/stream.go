@@ -6,38 +6,101 @@ import (
 	"orbservability/observer/pkg/config"
 	"time"
 
+	"github.com/rs/zerolog/log"
 	"px.dev/pxapi"
 	"px.dev/pxapi/errdefs"
 )
 
-func ExecuteAndStream(ctx context.Context, client *pxapi.Client, cfg *config.Config, tm *TableMux) error {
-	vz, err := client.NewVizierClient(ctx, cfg.VizierHost)
+func ExecuteAndStream(ctx context.Context, source *Source, script config.Script, cfg *config.Config, tm pxapi.TableMuxer) error {
+	vz, err := connectVizier(ctx, source)
 	if err != nil {
 		return err
 	}
+	return runExecutions(ctx, vz, source, script, cfg, tm)
+}
+
+// connectVizier returns a client of the Vizier of source, connecting source
+// first if needed.
+func connectVizier(ctx context.Context, source *Source) (vizier, error) {
+	client, err := source.connect()
+	if err != nil {
+		return nil, err
+	}
+	vz, err := client.NewVizierClient(ctx, source.Config.VizierHost)
+	if err != nil {
+		return nil, err
+	}
+	return vizierClient{vz}, nil
+}
+
+// runExecutions re-executes script until ctx is done, an unrecoverable
+// error occurs or execution fails more than cfg.Execution.MaxErrorCount times.
+// Each execution is closed and waited on before the next one starts.
+func runExecutions(ctx context.Context, vz vizier, source *Source, script config.Script, cfg *config.Config, tm pxapi.TableMuxer) error {
+	cadence := newCadence(cfg.Execution.IdleTimeout.Duration())
+	interval := cfg.Execution.Interval.Duration()
 
 	executionErrorCount := 0
 	for {
-		resultSet, err := vz.ExecuteScript(ctx, cfg.PxL, tm)
+		exec, err := startExecution(ctx, vz, script.PxL, tm, cadence.timeout())
 		if err != nil {
 			executionErrorCount++
-			if executionErrorCount > cfg.MaxErrorCount {
+			if executionErrorCount > cfg.Execution.MaxErrorCount {
+				return err
+			}
+			if err := sleepContext(ctx, cfg.Execution.Backoff.Delay(executionErrorCount)); err != nil {
 				return err
 			}
-			time.Sleep(time.Second * time.Duration(cfg.PixieStreamSleep))
 			continue
 		}
-		defer resultSet.Close()
 
-		if err := streamResults(resultSet); err != nil {
+		err = exec.stream()
+		exec.close()
+		exec.wait()
+		cadence.observe(exec)
+		if err != nil {
 			return err
 		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		executionErrorCount = 0 // Only failures in a row count towards MaxErrorCount
+
+		if exec.stalled() {
+			stalls := source.stalls.Add(1)
+			streamStalls.WithLabelValues(source.Config.Name, script.Name).Inc()
+			log.Warn().
+				Str("source", source.Config.Name).
+				Str("script", script.Name).
+				Dur("idle", exec.watchdog.idle()).
+				Dur("next_idle_timeout", cadence.timeout()).
+				Int64("stalls", stalls).
+				Msg("Pixie stream stalled, re-executing script")
+			continue
+		}
+		recordStats(source.Config.Name, script.Name, exec.tables(), exec.resultSet)
+		logStats(source.Config.Name, script.Name, exec)
+
+		if err := sleepContext(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext waits for d, returning early with ctx.Err() if ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-		time.Sleep(time.Second * time.Duration(cfg.PixieStreamSleep))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
 }
 
-func streamResults(resultSet *pxapi.ScriptResults) error {
+func streamResults(resultSet scriptResults) error {
 	for {
 		err := resultSet.Stream()
 		if err != nil {
@@ -0,0 +1,137 @@
+package pixie
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"orbservability/observer/pkg/config"
+
+	"px.dev/pxapi"
+	"px.dev/pxapi/errdefs"
+	"px.dev/pxapi/proto/vizierpb"
+	"px.dev/pxapi/types"
+)
+
+// streamingVizier executes streaming scripts, which return a table and then
+// stream until cancelled, except for the scripts in broken, which do not
+// compile, and those in compilesOnce after their first execution.
+type streamingVizier struct {
+	mu           sync.Mutex
+	broken       map[string]bool
+	compilesOnce map[string]bool
+	executions   map[string]int // By PxL
+}
+
+func (v *streamingVizier) ExecuteScript(ctx context.Context, pxl string, mux pxapi.TableMuxer) (scriptResults, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.executions[pxl]++
+	if v.broken[pxl] || v.compilesOnce[pxl] && v.executions[pxl] > 1 {
+		return nil, errdefs.ErrCompilation
+	}
+	return &streamingResults{ctx: ctx, mux: mux}, nil
+}
+
+func (v *streamingVizier) executed(pxl string) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.executions[pxl]
+}
+
+type streamingResults struct {
+	ctx context.Context
+	mux pxapi.TableMuxer
+}
+
+func (r *streamingResults) Stream() error {
+	metadata := types.TableMetadata{Name: "http_events"}
+	if _, err := r.mux.AcceptTable(r.ctx, metadata); err != nil {
+		return err
+	}
+	<-r.ctx.Done()
+	return r.ctx.Err()
+}
+
+func (r *streamingResults) Close() error                         { return nil }
+func (r *streamingResults) Stats() *vizierpb.QueryExecutionStats { return nil }
+
+func newTestSupervisor(vz vizier) *Supervisor {
+	s := newSupervisor(func(source string, script string) pxapi.TableMuxer { return nopMux{} })
+	s.connect = func(ctx context.Context, source *Source) (vizier, error) { return vz, nil }
+	return s
+}
+
+func testSupervisorConfig(scripts ...config.Script) *config.Config {
+	cfg := testExecutionConfig(time.Minute, 0)
+	cfg.Sources = []config.PixieSource{{Name: "pem"}}
+	cfg.Scripts = scripts
+	return cfg
+}
+
+func TestApplyRejectsScriptThatDoesNotCompile(t *testing.T) {
+	vz := &streamingVizier{broken: map[string]bool{"broken": true}, executions: map[string]int{}}
+	s := newTestSupervisor(vz)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := testSupervisorConfig(config.Script{Name: "http", PxL: "working"})
+	s.Start(ctx, []*Source{newSource(ctx, cfg.Sources[0])}, cfg)
+	running := s.workers[workerKey{"pem", "http"}]
+
+	err := s.Apply(testSupervisorConfig(config.Script{Name: "http", PxL: "broken"}, config.Script{Name: "dns", PxL: "working too"}))
+	if !errdefs.IsCompilationError(err) {
+		t.Fatalf("Apply returned %v, want a compilation error", err)
+	}
+	if len(s.workers) != 1 || s.workers[workerKey{"pem", "http"}] != running {
+		t.Fatalf("workers = %v, want the worker running before Apply only", s.workers)
+	}
+	select {
+	case <-running.done:
+		t.Fatal("worker running before Apply stopped")
+	default:
+	}
+	if s.cfg.Scripts[0].PxL != "working" {
+		t.Fatalf("script in effect = %q, want working", s.cfg.Scripts[0].PxL)
+	}
+	if n := vz.executed("working too"); n != 0 {
+		t.Fatalf("script of the rejected configuration executed %d times", n)
+	}
+
+	if err := s.Apply(testSupervisorConfig(config.Script{Name: "http", PxL: "fixed"})); err != nil {
+		t.Fatalf("Apply returned %v after the script was fixed", err)
+	}
+	<-running.done
+
+	cancel()
+	if err := s.Wait(); err != nil {
+		t.Fatalf("Wait returned %v, want nil", err)
+	}
+}
+
+func TestWorkerStartedByApplyDoesNotStopSupervisor(t *testing.T) {
+	vz := &streamingVizier{compilesOnce: map[string]bool{"changed": true}, executions: map[string]int{}}
+	s := newTestSupervisor(vz)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := testSupervisorConfig(config.Script{Name: "http", PxL: "working"})
+	s.Start(ctx, []*Source{newSource(ctx, cfg.Sources[0])}, cfg)
+
+	// The script compiles when Apply checks it, but no longer by the time its worker runs it
+	if err := s.Apply(testSupervisorConfig(config.Script{Name: "http", PxL: "changed"})); err != nil {
+		t.Fatal(err)
+	}
+	<-s.workers[workerKey{"pem", "http"}].done
+
+	select {
+	case err := <-s.errs:
+		t.Fatalf("supervisor stopped with %v", err)
+	default:
+	}
+	cancel()
+	if err := s.Wait(); err != nil {
+		t.Fatalf("Wait returned %v, want nil", err)
+	}
+}
@@ -6,18 +6,28 @@ import (
 	"reflect"
 
 	pb "orbservability/observer/pkg/gen/pb/v1"
+	"orbservability/observer/pkg/processor"
+	"orbservability/observer/pkg/sink"
 
+	"github.com/rs/zerolog/log"
 	"px.dev/pxapi/errdefs"
 	"px.dev/pxapi/types"
 )
 
+// TableMapper maps every record of a table to a PixieEvent and sends it to
+// the sinks. Printing events is left to the stdout sink.
 // Satisfies the TableRecordHandler interface.
-type TablePrinter struct {
+type TableMapper struct {
 	HeaderValues []string // A slice of strings to hold column names
-	GrpcStream   pb.EventGatewayService_StreamEventsClient
+	Sink         sink.Sink
+	Source       string // Name of the Pixie source, set on every event
+	Processors   processor.Processor
+	TableName    string
+	Records      int64 // Records handled since HandleInit
 }
 
-func (t *TablePrinter) HandleInit(ctx context.Context, metadata types.TableMetadata) error {
+func (t *TableMapper) HandleInit(ctx context.Context, metadata types.TableMetadata) error {
+	t.TableName = metadata.Name
 	// Store column names in order
 	for _, col := range metadata.ColInfo {
 		t.HeaderValues = append(t.HeaderValues, col.Name)
@@ -25,7 +35,7 @@ func (t *TablePrinter) HandleInit(ctx context.Context, metadata types.TableMetad
 	return nil
 }
 
-func (t *TablePrinter) HandleRecord(ctx context.Context, r *types.Record) error {
+func (t *TableMapper) HandleRecord(ctx context.Context, r *types.Record) error {
 	if len(r.Data) != len(t.HeaderValues) {
 		return fmt.Errorf("%w: mismatch in header and data sizes", errdefs.ErrInvalidArgument)
 	}
@@ -50,14 +60,24 @@ func (t *TablePrinter) HandleRecord(ctx context.Context, r *types.Record) error
 
 		field.Set(fieldValue)
 	}
+	msg.Source = t.Source
 
-	if err := t.GrpcStream.Send(msg); err != nil {
+	if !t.Processors.Process(msg) {
+		return nil // Dropped by a processor
+	}
+	if err := t.Sink.Send(ctx, msg); err != nil {
 		return err
 	}
+	t.Records++
 
 	return nil
 }
 
-func (t *TablePrinter) HandleDone(ctx context.Context) error {
+func (t *TableMapper) HandleDone(ctx context.Context) error {
+	log.Debug().
+		Str("source", t.Source).
+		Str("table", t.TableName).
+		Int64("records", t.Records).
+		Msg("Pixie table done")
 	return nil
 }
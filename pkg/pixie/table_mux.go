@@ -3,7 +3,8 @@ package pixie
 import (
 	"context"
 
-	pb "orbservability/observer/pkg/gen/pb/v1"
+	"orbservability/observer/pkg/processor"
+	"orbservability/observer/pkg/sink"
 
 	"px.dev/pxapi"
 	"px.dev/pxapi/types"
@@ -11,11 +12,15 @@ import (
 
 // Satisfies the TableMuxer interface.
 type TableMux struct {
-	GrpcStream pb.EventGatewayService_StreamEventsClient
+	Sink       sink.Sink
+	Source     string // Name of the Pixie source the tables come from
+	Processors processor.Processor
 }
 
 func (s *TableMux) AcceptTable(ctx context.Context, metadata types.TableMetadata) (pxapi.TableRecordHandler, error) {
-	return &TablePrinter{
-		GrpcStream: s.GrpcStream,
+	return &TableMapper{
+		Sink:       s.Sink,
+		Source:     s.Source,
+		Processors: s.Processors,
 	}, nil
 }
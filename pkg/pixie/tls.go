@@ -0,0 +1,146 @@
+package pixie
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const tlsHandshakeTimeout = 10 * time.Second
+
+// verifyTLS performs a single handshake against addr so that an untrusted
+// certificate chain is reported at startup rather than on the first script execution.
+func verifyTLS(ctx context.Context, addr string, tlsConfig *tls.Config) error {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: tlsHandshakeTimeout},
+		Config:    tlsConfig,
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		var unknownAuthority x509.UnknownAuthorityError
+		var hostname x509.HostnameError
+		var invalid x509.CertificateInvalidError
+		switch {
+		case errors.As(err, &unknownAuthority):
+			return misconfiguredError{fmt.Errorf("TLS verification of Pixie at %s failed, certificate is not signed by a trusted CA: %w", addr, err)}
+		case errors.As(err, &hostname):
+			return misconfiguredError{fmt.Errorf("TLS verification of Pixie at %s failed, certificate does not match server name %q: %w", addr, tlsConfig.ServerName, err)}
+		case errors.As(err, &invalid):
+			return misconfiguredError{fmt.Errorf("TLS verification of Pixie at %s failed, certificate is invalid: %w", addr, err)}
+		}
+		return fmt.Errorf("TLS handshake with Pixie at %s failed: %w", addr, err)
+	}
+	return conn.Close()
+}
+
+// tlsBridge listens on a Unix socket in a directory only the observer's user
+// can enter, and forwards every accepted connection to the PEM over TLS. pxapi
+// only offers insecure credentials for direct connections and no way to pass
+// a dialer, so the client is pointed at the bridge instead. A loopback TCP
+// port would let any local process, or any pod sharing the network
+// namespace, reach the PEM with the observer's client certificate.
+type tlsBridge struct {
+	listener  net.Listener
+	path      string
+	target    string
+	tlsConfig *tls.Config
+	wg        sync.WaitGroup
+}
+
+func newTLSBridge(ctx context.Context, target string, tlsConfig *tls.Config) (*tlsBridge, error) {
+	dir, err := os.MkdirTemp("", "observer-pixie-") // Created 0700
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "pem.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	b := &tlsBridge{
+		listener:  listener,
+		path:      path,
+		target:    target,
+		tlsConfig: tlsConfig,
+	}
+	go b.serve(ctx)
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+		os.RemoveAll(dir)
+	}()
+
+	return b, nil
+}
+
+// Addr is the plaintext address pxapi should dial.
+func (b *tlsBridge) Addr() string {
+	return "unix://" + b.path
+}
+
+func (b *tlsBridge) serve(ctx context.Context) {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				log.Error().Err(err).Msg("Error accepting Pixie TLS bridge connection")
+			}
+			b.wg.Wait()
+			return
+		}
+
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.forward(ctx, conn)
+		}()
+	}
+}
+
+func (b *tlsBridge) forward(ctx context.Context, local net.Conn) {
+	defer local.Close()
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: tlsHandshakeTimeout},
+		Config:    b.tlsConfig,
+	}
+	remote, err := dialer.DialContext(ctx, "tcp", b.target)
+	if err != nil {
+		log.Error().Err(err).Str("addr", b.target).Msg("Error connecting to Pixie over TLS")
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+
+	// Either side closing ends the session
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
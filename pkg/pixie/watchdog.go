@@ -0,0 +1,110 @@
+package pixie
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// watchdog cancels a script execution when no table activity has been seen
+// for longer than the idle timeout. The PEM can stop sending data without
+// closing the stream, which otherwise leaves resultSet.Stream() blocked forever.
+type watchdog struct {
+	timeout  time.Duration
+	lastSeen atomic.Int64 // Unix nanoseconds of the last activity
+	maxGap   atomic.Int64 // Longest time between two activities, in nanoseconds
+	stalled  atomic.Bool
+}
+
+func newWatchdog(timeout time.Duration) *watchdog {
+	w := &watchdog{timeout: timeout}
+	w.lastSeen.Store(time.Now().UnixNano())
+	return w
+}
+
+func (w *watchdog) touch() {
+	if w == nil {
+		return // Idle timeout disabled
+	}
+	now := time.Now().UnixNano()
+	// Only the goroutine streaming the execution touches, so no update is lost
+	if gap := now - w.lastSeen.Swap(now); gap > w.maxGap.Load() {
+		w.maxGap.Store(gap)
+	}
+}
+
+func (w *watchdog) idle() time.Duration {
+	return time.Since(time.Unix(0, w.lastSeen.Load()))
+}
+
+// watch calls cancel once the execution has been idle for longer than the
+// timeout, and returns when ctx is done.
+func (w *watchdog) watch(ctx context.Context, cancel context.CancelFunc) {
+	interval := w.timeout / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if w.idle() > w.timeout {
+				w.stalled.Store(true)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+const (
+	cadenceGapFactor = 3  // Idle timeout as a multiple of the longest gap seen
+	maxIdleScale     = 16 // Idle timeout at most this many times the configured one
+)
+
+// cadence adapts the idle timeout of a script's executions to how often its
+// source actually sends data, so that a quiet cluster is not mistaken for a
+// stalled stream. The timeout is raised to a multiple of the longest gap seen
+// between the activity of earlier executions, and doubled for every stall in
+// a row in which no record arrived, up to maxIdleScale times the configured
+// timeout. A record or an execution that completes resets the doubling.
+type cadence struct {
+	base   time.Duration
+	gap    time.Duration // Longest gap between activity seen so far
+	stalls int           // Stalls in a row without a record
+}
+
+func newCadence(base time.Duration) *cadence {
+	return &cadence{base: base}
+}
+
+// timeout returns the idle timeout of the next execution, 0 when disabled.
+func (c *cadence) timeout() time.Duration {
+	if c.base <= 0 {
+		return 0
+	}
+	limit := c.base * maxIdleScale
+	timeout := max(c.base, cadenceGapFactor*c.gap)
+	for i := 0; i < c.stalls && timeout < limit; i++ {
+		timeout *= 2
+	}
+	return min(timeout, limit)
+}
+
+// observe adapts the timeout to a finished execution.
+func (c *cadence) observe(e *execution) {
+	if e.watchdog == nil {
+		return
+	}
+	if e.stalled() && e.records() == 0 {
+		c.stalls++
+	} else {
+		c.stalls = 0
+	}
+	// maxGap excludes the idle time that ended in a stall, which no activity closed
+	c.gap = max(c.gap, time.Duration(e.watchdog.maxGap.Load()))
+}
@@ -0,0 +1,151 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/event"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+// Processor decides whether an event continues down the pipeline.
+type Processor interface {
+	Process(e *pb.PixieEvent) bool
+}
+
+// Chain applies processors in order and keeps an event only if all of them do.
+type Chain []Processor
+
+func (c Chain) Process(e *pb.PixieEvent) bool {
+	for _, p := range c {
+		if !p.Process(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// Pipeline is a Chain that can be replaced while events are being processed,
+// so that a configuration reload does not need to restart the Pixie streams.
+type Pipeline struct {
+	chain atomic.Pointer[Chain]
+}
+
+func NewPipeline(chain Chain) *Pipeline {
+	p := &Pipeline{}
+	p.Store(chain)
+	return p
+}
+
+// Store replaces the chain used for subsequent events.
+func (p *Pipeline) Store(chain Chain) {
+	p.chain.Store(&chain)
+}
+
+func (p *Pipeline) Process(e *pb.PixieEvent) bool {
+	return p.chain.Load().Process(e)
+}
+
+// New builds the processing chain described by cfgs.
+func New(cfgs []config.ProcessorConfig) (Chain, error) {
+	chain := make(Chain, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		switch cfg.Type {
+		case "filter":
+			chain = append(chain, &Filter{
+				Namespaces:        cfg.Namespaces,
+				ExcludeNamespaces: cfg.ExcludeNamespaces,
+				Services:          cfg.Services,
+				ExcludeServices:   cfg.ExcludeServices,
+				Protocols:         cfg.Protocols,
+			})
+		case "sample":
+			// A rate of 0, which an unset rate defaults to, would discard every event
+			if cfg.Rate <= 0 || cfg.Rate > 1 {
+				return nil, fmt.Errorf("processors[%d]: sample rate must be greater than 0 and at most 1, got %g", i, cfg.Rate)
+			}
+			chain = append(chain, &Sampler{Rate: cfg.Rate})
+		case "rate_limit":
+			if cfg.Rate <= 0 || cfg.Burst < 0 {
+				return nil, fmt.Errorf("processors[%d]: rate limit must be greater than 0 with a burst of at least 0, got %g and %d", i, cfg.Rate, cfg.Burst)
+			}
+			chain = append(chain, NewRateLimiter(cfg.Rate, cfg.Burst))
+		default:
+			return nil, fmt.Errorf("processors[%d]: unknown type %q", i, cfg.Type)
+		}
+	}
+	return chain, nil
+}
+
+// Filter keeps events matching the allow-lists and none of the deny-lists.
+// An empty allow-list matches everything.
+type Filter struct {
+	Namespaces        []string
+	ExcludeNamespaces []string
+	Services          []string
+	ExcludeServices   []string
+	Protocols         []string
+}
+
+func (f *Filter) Process(e *pb.PixieEvent) bool {
+	return allowed(f.Namespaces, f.ExcludeNamespaces, e.KubernetesNamespace) &&
+		allowed(f.Services, f.ExcludeServices, e.KubernetesService) &&
+		allowed(f.Protocols, nil, event.Protocol(e))
+}
+
+func allowed(include []string, exclude []string, value string) bool {
+	if len(include) > 0 && !slices.Contains(include, value) {
+		return false
+	}
+	return !slices.Contains(exclude, value)
+}
+
+// Sampler keeps a random fraction of events.
+type Sampler struct {
+	Rate float64
+}
+
+func (s *Sampler) Process(e *pb.PixieEvent) bool {
+	return rand.Float64() < s.Rate
+}
+
+// RateLimiter keeps at most Rate events per second on average, and up to Burst
+// events at once after a quiet period.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64 // Events that can be kept now
+	last   time.Time
+}
+
+// NewRateLimiter returns a RateLimiter that can keep burst events right away.
+// A burst of 0 defaults to the rate rounded up.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	b := float64(burst)
+	if burst == 0 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &RateLimiter{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+func (l *RateLimiter) Process(e *pb.PixieEvent) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
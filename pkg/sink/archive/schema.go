@@ -0,0 +1,89 @@
+package archive
+
+import (
+	"orbservability/observer/pkg/event"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"github.com/parquet-go/parquet-go"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// schemaOf returns the columns of the events of a protocol: the event fields,
+// with the time as a timestamp, followed by the fields of the protocol data,
+// all named after their protobuf fields and optional.
+func schemaOf(protocol string) *parquet.Schema {
+	columns := parquet.Group{}
+	descriptor := (&pb.PixieEvent{}).ProtoReflect().Descriptor()
+	fields := descriptor.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		switch {
+		case field.Name() == "time":
+			columns["time"] = parquet.Optional(parquet.Timestamp(parquet.Nanosecond))
+		case field.Name() == "api_key":
+			// Only set on the events sent to the gateway
+		case field.Kind() == protoreflect.MessageKind:
+			if string(field.Name()) != protocol {
+				continue
+			}
+			protocolFields := field.Message().Fields()
+			for j := 0; j < protocolFields.Len(); j++ {
+				if node := leaf(protocolFields.Get(j)); node != nil {
+					columns[string(protocolFields.Get(j).Name())] = node
+				}
+			}
+		default:
+			if node := leaf(field); node != nil {
+				columns[string(field.Name())] = node
+			}
+		}
+	}
+	return parquet.NewSchema("pixie_event", columns)
+}
+
+// leaf returns the column of a scalar field, or nil for kinds the events do not use.
+func leaf(field protoreflect.FieldDescriptor) parquet.Node {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return parquet.Optional(parquet.String())
+	case protoreflect.BoolKind:
+		return parquet.Optional(parquet.Leaf(parquet.BooleanType))
+	case protoreflect.Int32Kind:
+		return parquet.Optional(parquet.Int(32))
+	case protoreflect.Int64Kind:
+		return parquet.Optional(parquet.Int(64))
+	default:
+		return nil
+	}
+}
+
+// row flattens an event into the columns of its protocol's schema. Unset
+// fields are left out and written as nulls.
+func row(e *pb.PixieEvent) map[string]any {
+	values := map[string]any{}
+	if at, ok := event.Time(e); ok {
+		values["time"] = at.UTC()
+	}
+	var add func(m protoreflect.Message)
+	add = func(m protoreflect.Message) {
+		m.Range(func(field protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			switch field.Kind() {
+			case protoreflect.MessageKind:
+				add(v.Message())
+			case protoreflect.StringKind:
+				if field.Name() != "time" {
+					values[string(field.Name())] = v.String()
+				}
+			case protoreflect.BoolKind:
+				values[string(field.Name())] = v.Bool()
+			case protoreflect.Int32Kind:
+				values[string(field.Name())] = int32(v.Int())
+			case protoreflect.Int64Kind:
+				values[string(field.Name())] = v.Int()
+			}
+			return true
+		})
+	}
+	add(e.ProtoReflect())
+	return values
+}
@@ -0,0 +1,193 @@
+// Package archive writes events into Parquet files partitioned by protocol
+// and hour, for querying later with tools such as DuckDB or Spark.
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/event"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	"github.com/rs/zerolog/log"
+)
+
+// partitionTime names the files after the time they were opened, like the
+// rotated files of the file sink.
+const partitionTime = "20060102T150405.000Z"
+
+// Sink keeps a file open per partition until it is full or old enough.
+// Sink is not safe for concurrent use.
+type Sink struct {
+	cfg     config.ParquetSinkConfig
+	codec   compress.Codec
+	schemas map[string]*parquet.Schema // By protocol
+	files   map[partition]*file
+	seq     int // Distinguishes files opened in the same millisecond
+
+	mu  sync.Mutex
+	err error // Last write error, reported by Health
+}
+
+// partition is the protocol and hour files are partitioned by.
+type partition struct {
+	protocol string
+	hour     time.Time
+}
+
+// dir returns the Hive style directory of the partition, e.g. protocol=http/date=2024-01-01/hour=13.
+func (p partition) dir() string {
+	return filepath.Join("protocol="+p.protocol, "date="+p.hour.Format("2006-01-02"), "hour="+p.hour.Format("15"))
+}
+
+// file is an open Parquet file, written under a temporary name.
+type file struct {
+	path   string // Final name, the file is written to path + ".tmp"
+	f      *os.File
+	writer *parquet.Writer
+	rows   int
+	opened time.Time
+}
+
+func New(cfg config.ParquetSinkConfig) (*Sink, error) {
+	s := &Sink{
+		cfg:     cfg,
+		schemas: map[string]*parquet.Schema{},
+		files:   map[partition]*file{},
+	}
+	switch cfg.Compression {
+	case "zstd":
+		s.codec = &parquet.Zstd
+	case "snappy":
+		s.codec = &parquet.Snappy
+	case "gzip":
+		s.codec = &parquet.Gzip
+	case "none":
+		s.codec = &parquet.Uncompressed
+	default:
+		return nil, fmt.Errorf("unknown compression %q", cfg.Compression)
+	}
+	return s, nil
+}
+
+func (s *Sink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	at, ok := event.Time(e)
+	if !ok {
+		at = time.Now()
+	}
+	protocol := event.Protocol(e)
+	if protocol == "" {
+		protocol = "unknown"
+	}
+	p := partition{protocol: protocol, hour: at.UTC().Truncate(time.Hour)}
+
+	f, err := s.file(p)
+	if err == nil {
+		err = f.writer.Write(row(e))
+	}
+	if err == nil {
+		if f.rows++; f.rows >= s.cfg.MaxRows {
+			err = s.close(p)
+		}
+	}
+	s.setErr(err)
+	return err
+}
+
+// Flush closes the files that have been open for the longest allowed.
+// Open files are not flushed, as Parquet files can only be read once closed.
+func (s *Sink) Flush(ctx context.Context) error {
+	var errs []error
+	for p, f := range s.files {
+		if time.Since(f.opened) >= s.cfg.MaxAge.Duration() {
+			errs = append(errs, s.close(p))
+		}
+	}
+	err := errors.Join(errs...)
+	s.setErr(err)
+	return err
+}
+
+// Close closes every open file.
+func (s *Sink) Close(ctx context.Context) error {
+	var errs []error
+	for p := range s.files {
+		errs = append(errs, s.close(p))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Sink) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *Sink) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// file returns the open file of a partition, opening a new one if needed.
+// Files are readable by their owner only, since events hold request and
+// response bodies.
+func (s *Sink) file(p partition) (*file, error) {
+	if f, found := s.files[p]; found {
+		return f, nil
+	}
+	schema, found := s.schemas[p.protocol]
+	if !found {
+		schema = schemaOf(p.protocol)
+		s.schemas[p.protocol] = schema
+	}
+
+	dir := filepath.Join(s.cfg.Dir, p.dir())
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	s.seq++
+	path := filepath.Join(dir, "part-"+now.UTC().Format(partitionTime)+"-"+strconv.Itoa(s.seq)+".parquet")
+	out, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &file{
+		path:   path,
+		f:      out,
+		writer: parquet.NewWriter(out, schema, parquet.Compression(s.codec)),
+		opened: now,
+	}
+	s.files[p] = f
+	return f, nil
+}
+
+// close finishes the file of a partition and gives it its final name.
+func (s *Sink) close(p partition) error {
+	f := s.files[p]
+	delete(s.files, p)
+
+	err := f.writer.Close()
+	if closeErr := f.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("closing %s: %w", f.path, err)
+	}
+	if err := os.Rename(f.path+".tmp", f.path); err != nil {
+		return err
+	}
+	log.Debug().Str("path", f.path).Int("rows", f.rows).Msg("Closed Parquet file")
+	return nil
+}
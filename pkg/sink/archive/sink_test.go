@@ -0,0 +1,275 @@
+package archive
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// httpRow is a row of an HTTP partition, as read back by the tests.
+type httpRow struct {
+	Time                *time.Time `parquet:"time,optional"`
+	Upid                *string    `parquet:"upid,optional"`
+	IsServerSideTracing *bool      `parquet:"is_server_side_tracing,optional"`
+	Latency             *int64     `parquet:"latency,optional"`
+	ReqMethod           *string    `parquet:"req_method,optional"`
+	ReqPath             *string    `parquet:"req_path,optional"`
+	RespStatus          *int32     `parquet:"resp_status,optional"`
+}
+
+func openSink(t *testing.T, cfg config.ParquetSinkConfig) *Sink {
+	t.Helper()
+	cfg.Dir = t.TempDir()
+	if cfg.MaxRows == 0 {
+		cfg.MaxRows = 1000
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = config.Duration(time.Hour)
+	}
+	cfg.Compression = "zstd"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func send(t *testing.T, s *Sink, events ...*pb.PixieEvent) {
+	t.Helper()
+	for _, e := range events {
+		if err := s.Send(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// closed returns the paths of the closed files below dir, relative to it and sorted.
+func closed(t *testing.T, dir string) []string {
+	t.Helper()
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".parquet") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		paths = append(paths, rel)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+func httpEvent(at time.Time, upid string) *pb.PixieEvent {
+	return &pb.PixieEvent{
+		Time:                at.Format(time.RFC3339Nano),
+		Upid:                upid,
+		IsServerSideTracing: true,
+		Latency:             1200,
+		ProtocolData: &pb.PixieEvent_Http{Http: &pb.HypertextTransferProtocol{
+			ReqMethod:  "GET",
+			ReqPath:    "/health",
+			RespStatus: 200,
+		}},
+	}
+}
+
+func TestPartitionsByProtocolAndHour(t *testing.T) {
+	s := openSink(t, config.ParquetSinkConfig{})
+	at := time.Date(2024, 1, 1, 13, 5, 0, 0, time.UTC)
+	send(t, s,
+		httpEvent(at, "1"),
+		httpEvent(at.Add(time.Hour), "2"),
+		&pb.PixieEvent{Time: at.Format(time.RFC3339Nano), ProtocolData: &pb.PixieEvent_Pgsql{Pgsql: &pb.PostgreSQL{ReqCmd: "Query"}}},
+		&pb.PixieEvent{Time: at.Format(time.RFC3339Nano)},
+	)
+	if got := closed(t, s.cfg.Dir); len(got) != 0 {
+		t.Fatalf("closed files %q before Close, want none", got)
+	}
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := closed(t, s.cfg.Dir)
+	want := []string{
+		"protocol=http/date=2024-01-01/hour=13",
+		"protocol=http/date=2024-01-01/hour=14",
+		"protocol=pgsql/date=2024-01-01/hour=13",
+		"protocol=unknown/date=2024-01-01/hour=13",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("closed files %q, want one in each of %q", got, want)
+	}
+	for i := range want {
+		if dir, name := filepath.Split(got[i]); filepath.Clean(dir) != filepath.FromSlash(want[i]) || !strings.HasPrefix(name, "part-") {
+			t.Errorf("closed file %s, want a part file in %s", got[i], want[i])
+		}
+		path := filepath.Join(s.cfg.Dir, got[i])
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("%s has mode %v, %v, want 0600", got[i], info.Mode().Perm(), err)
+		}
+	}
+}
+
+func TestWritesTypedColumns(t *testing.T) {
+	s := openSink(t, config.ParquetSinkConfig{})
+	at := time.Date(2024, 1, 1, 13, 5, 0, 123456789, time.UTC)
+	send(t, s, httpEvent(at, "1"), &pb.PixieEvent{Time: at.Format(time.RFC3339Nano), ProtocolData: &pb.PixieEvent_Pgsql{Pgsql: &pb.PostgreSQL{ReqCmd: "Query"}}})
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	files := closed(t, s.cfg.Dir)
+	if len(files) != 2 {
+		t.Fatalf("closed files %q, want an HTTP and a PostgreSQL file", files)
+	}
+
+	path := filepath.Join(s.cfg.Dir, files[0])
+	rows, err := parquet.ReadFile[httpRow](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("%s holds %d rows, want 1", files[0], len(rows))
+	}
+	row := rows[0]
+	if row.Time == nil || !row.Time.Equal(at) || *row.Upid != "1" || !*row.IsServerSideTracing || *row.Latency != 1200 ||
+		*row.ReqMethod != "GET" || *row.ReqPath != "/health" || *row.RespStatus != 200 {
+		t.Errorf("%s holds %+v, want the event", files[0], row)
+	}
+
+	tests := []struct {
+		file    string
+		column  string
+		kind    parquet.Kind
+		missing bool
+	}{
+		{file: files[0], column: "time", kind: parquet.Int64},
+		{file: files[0], column: "latency", kind: parquet.Int64},
+		{file: files[0], column: "remote_port", kind: parquet.Int32},
+		{file: files[0], column: "is_server_side_tracing", kind: parquet.Boolean},
+		{file: files[0], column: "resp_status", kind: parquet.Int32},
+		{file: files[0], column: "req_body_size", kind: parquet.Int64},
+		{file: files[0], column: "req_path", kind: parquet.ByteArray},
+		{file: files[0], column: "req_cmd", missing: true},
+		{file: files[0], column: "api_key", missing: true},
+		{file: files[1], column: "req_cmd", kind: parquet.ByteArray},
+		{file: files[1], column: "resp_status", missing: true},
+	}
+	schemas := map[string]*parquet.Schema{}
+	for _, file := range files {
+		f, err := os.Open(filepath.Join(s.cfg.Dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pf, err := parquet.OpenFile(f, info.Size())
+		if err != nil {
+			t.Fatal(err)
+		}
+		schemas[file] = pf.Schema()
+	}
+	for _, tt := range tests {
+		column, found := schemas[tt.file].Lookup(tt.column)
+		switch {
+		case tt.missing && found:
+			t.Errorf("%s has a %s column, want none", tt.file, tt.column)
+		case tt.missing:
+		case !found:
+			t.Errorf("%s has no %s column", tt.file, tt.column)
+		case column.Node.Type().Kind() != tt.kind || !column.Node.Optional():
+			t.Errorf("%s column %s is %v, optional = %t, want optional %v", tt.file, tt.column, column.Node.Type(), column.Node.Optional(), tt.kind)
+		}
+	}
+	column, _ := schemas[files[0]].Lookup("time")
+	if logical := column.Node.Type().LogicalType(); logical == nil || logical.Timestamp == nil {
+		t.Errorf("time column is %v, want a timestamp", column.Node.Type())
+	}
+}
+
+func TestRotatesByMaxRows(t *testing.T) {
+	s := openSink(t, config.ParquetSinkConfig{MaxRows: 2})
+	at := time.Date(2024, 1, 1, 13, 5, 0, 0, time.UTC)
+	send(t, s, httpEvent(at, "1"), httpEvent(at, "2"), httpEvent(at, "3"), httpEvent(at, "4"), httpEvent(at, "5"))
+
+	// Full files are closed right away, the last one on Close
+	if got := closed(t, s.cfg.Dir); len(got) != 2 {
+		t.Fatalf("closed files %q, want the 2 full files", got)
+	}
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	files := closed(t, s.cfg.Dir)
+	if len(files) != 3 {
+		t.Fatalf("closed files %q, want 3", files)
+	}
+	for i, want := range [][]string{{"1", "2"}, {"3", "4"}, {"5"}} {
+		rows, err := parquet.ReadFile[httpRow](filepath.Join(s.cfg.Dir, files[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var upids []string
+		for _, row := range rows {
+			upids = append(upids, *row.Upid)
+		}
+		if !slices.Equal(upids, want) {
+			t.Errorf("%s holds %q, want %q", files[i], upids, want)
+		}
+	}
+}
+
+func TestRotatesByMaxAge(t *testing.T) {
+	s := openSink(t, config.ParquetSinkConfig{MaxAge: config.Duration(time.Minute)})
+	at := time.Date(2024, 1, 1, 13, 5, 0, 0, time.UTC)
+	send(t, s, httpEvent(at, "1"))
+
+	if err := s.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := closed(t, s.cfg.Dir); len(got) != 0 {
+		t.Fatalf("closed files %q after flushing a new file, want none", got)
+	}
+
+	for _, f := range s.files {
+		f.opened = f.opened.Add(-time.Minute)
+	}
+	if err := s.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := closed(t, s.cfg.Dir); len(got) != 1 {
+		t.Fatalf("closed files %q after flushing an old file, want 1", got)
+	}
+
+	send(t, s, httpEvent(at, "2"))
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	files := closed(t, s.cfg.Dir)
+	if len(files) != 2 {
+		t.Fatalf("closed files %q, want the later event in a new file", files)
+	}
+	for i, want := range []string{"1", "2"} {
+		rows, err := parquet.ReadFile[httpRow](filepath.Join(s.cfg.Dir, files[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || *rows[0].Upid != want {
+			t.Errorf("%s holds %+v, want the event %s", files[i], rows, want)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Package file writes events as JSON lines to local files, rotating them by
+// size and age.
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// rotatedTime is appended to the names of rotated files, followed by a
+// sequence number. It sorts lexically in the order files were rotated.
+const rotatedTime = "20060102T150405.000Z"
+
+// Sink appends events to a file as protojson lines. Sink is not safe for concurrent use.
+type Sink struct {
+	cfg     config.FileSinkConfig
+	maxSize int64
+	maxAge  time.Duration
+
+	file   *os.File
+	w      *bufio.Writer
+	size   int64     // Bytes written to file, including those still buffered
+	opened time.Time // When file was created, or last modified before this sink opened it
+	seq    int       // Distinguishes files rotated in the same millisecond
+
+	background sync.WaitGroup // Compression and retention of rotated files
+	rotations  sync.Mutex     // Serialises the background work of successive rotations
+
+	mu  sync.Mutex
+	err error // Last write error, reported by Health
+}
+
+// New opens cfg.Path for appending, creating it readable by its owner only if
+// needed, since events hold request and response bodies.
+func New(cfg config.FileSinkConfig) (*Sink, error) {
+	s := &Sink{
+		cfg:     cfg,
+		maxSize: int64(cfg.MaxSizeMB) << 20,
+		maxAge:  cfg.MaxAge.Duration(),
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sink) open() error {
+	f, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.w = bufio.NewWriter(f)
+	s.size = info.Size()
+	s.opened = time.Now()
+	if s.size > 0 {
+		s.opened = info.ModTime() // Age is that of the file, not of the process
+	}
+	return nil
+}
+
+func (s *Sink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	line, err := protojson.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	return s.record(s.write(line))
+}
+
+func (s *Sink) write(line []byte) error {
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.size > 0 && (s.tooOld() || s.maxSize > 0 && s.size+int64(len(line)) > s.maxSize) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.w.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// Flush writes the buffered lines to the file, first rotating it if it is too old.
+func (s *Sink) Flush(ctx context.Context) error {
+	if s.file == nil {
+		return nil
+	}
+	if s.size > 0 && s.tooOld() {
+		return s.record(s.rotate())
+	}
+	return s.record(s.w.Flush())
+}
+
+// Close closes the file and waits for rotated files to be compressed.
+func (s *Sink) Close(ctx context.Context) error {
+	err := s.close()
+	s.background.Wait()
+	return err
+}
+
+func (s *Sink) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *Sink) record(err error) error {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+	return err
+}
+
+func (s *Sink) tooOld() bool {
+	return s.maxAge > 0 && time.Since(s.opened) >= s.maxAge
+}
+
+func (s *Sink) close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.w.Flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+	return err
+}
+
+// rotate renames the file out of the way and opens a new one. The rotated
+// file is compressed and old files removed in the background.
+func (s *Sink) rotate() error {
+	if err := s.close(); err != nil {
+		return err
+	}
+
+	dir, prefix, ext := s.names()
+	stamp := time.Now().UTC().Format(rotatedTime)
+	var rotated string
+	for {
+		s.seq++
+		rotated = filepath.Join(dir, prefix+stamp+"-"+strconv.Itoa(s.seq)+ext)
+		if !exists(rotated) && !exists(rotated+".gz") {
+			break
+		}
+	}
+	if err := os.Rename(s.cfg.Path, rotated); err != nil {
+		return err
+	}
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		s.rotations.Lock()
+		defer s.rotations.Unlock()
+
+		if s.cfg.Compress {
+			// Unless already pruned by a later rotation, whose work can run first
+			if err := compress(rotated); err != nil && !os.IsNotExist(err) {
+				log.Error().Err(err).Str("file", rotated).Msg("Error compressing rotated file")
+			}
+		}
+		if err := s.prune(); err != nil {
+			log.Error().Err(err).Str("path", s.cfg.Path).Msg("Error removing old rotated files")
+		}
+	}()
+
+	return s.open()
+}
+
+// names splits the path into the directory, and the prefix and extension of
+// rotated file names.
+func (s *Sink) names() (dir string, prefix string, ext string) {
+	dir, base := filepath.Split(s.cfg.Path)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+// prune removes the oldest rotated files beyond cfg.MaxFiles.
+func (s *Sink) prune() error {
+	if s.cfg.MaxFiles < 0 {
+		return nil
+	}
+
+	dir, prefix, ext := s.names()
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return err
+	}
+	var rotated []rotatedFile
+	for _, entry := range entries {
+		if f, ok := parseRotated(entry.Name(), prefix, ext); ok {
+			rotated = append(rotated, f)
+		}
+	}
+	if len(rotated) <= s.cfg.MaxFiles {
+		return nil
+	}
+
+	slices.SortFunc(rotated, func(a, b rotatedFile) int {
+		if c := strings.Compare(a.stamp, b.stamp); c != 0 {
+			return c
+		}
+		return a.seq - b.seq
+	})
+	for _, f := range rotated[:len(rotated)-s.cfg.MaxFiles] {
+		if err := os.Remove(filepath.Join(dir, f.name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotatedFile is a file rotated by a Sink.
+type rotatedFile struct {
+	name  string
+	stamp string // When it was rotated, in rotatedTime
+	seq   int    // Order among the files rotated at stamp
+}
+
+// parseRotated parses the name of a rotated file, compressed or not. Files
+// rotated before sequence numbers were added have none.
+func parseRotated(name string, prefix string, ext string) (rotatedFile, bool) {
+	rest, found := strings.CutPrefix(name, prefix)
+	if !found {
+		return rotatedFile{}, false
+	}
+	rest, found = strings.CutSuffix(strings.TrimSuffix(rest, ".gz"), ext)
+	if !found {
+		return rotatedFile{}, false
+	}
+	f := rotatedFile{name: name, stamp: rest}
+	if stamp, seq, found := strings.Cut(rest, "-"); found {
+		n, err := strconv.Atoi(seq)
+		if err != nil {
+			return rotatedFile{}, false
+		}
+		f.stamp, f.seq = stamp, n
+	}
+	if _, err := time.Parse(rotatedTime, f.stamp); err != nil {
+		return rotatedFile{}, false
+	}
+	return f, true
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// compress replaces path with a gzip compressed path.gz.
+func compress(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return fmt.Errorf("closing %s: %w", out.Name(), err)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,210 @@
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+// line is what an event with upid is written as.
+func line(upid string) string {
+	return `{"upid":"` + upid + `"}`
+}
+
+// openSink opens a sink writing to events.jsonl in a new directory, rotating
+// the file once it would hold more than two lines.
+func openSink(t *testing.T, cfg config.FileSinkConfig) *Sink {
+	t.Helper()
+	if cfg.Path == "" {
+		cfg.Path = filepath.Join(t.TempDir(), "events.jsonl")
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.maxSize = int64(2 * (len(line("1")) + 1))
+	return s
+}
+
+func send(t *testing.T, s *Sink, upids ...string) {
+	t.Helper()
+	for _, upid := range upids {
+		if err := s.Send(context.Background(), &pb.PixieEvent{Upid: upid}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// rotated returns the rotated files next to the sink's file, oldest first.
+func rotated(t *testing.T, s *Sink) []rotatedFile {
+	t.Helper()
+	dir, prefix, ext := s.names()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []rotatedFile
+	for _, entry := range entries {
+		if f, ok := parseRotated(entry.Name(), prefix, ext); ok {
+			files = append(files, f)
+		}
+	}
+	slices.SortFunc(files, func(a, b rotatedFile) int { return a.seq - b.seq })
+	return files
+}
+
+// readLines returns the lines of path, decompressing it if it is gzipped.
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("%s is not gzipped: %v", path, err)
+		}
+		r = gz
+	}
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, strings.ReplaceAll(scanner.Text(), " ", ""))
+	}
+	return lines
+}
+
+func TestRotatesBySize(t *testing.T) {
+	s := openSink(t, config.FileSinkConfig{MaxFiles: -1})
+	send(t, s, "1", "2", "3", "4", "5")
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	files := rotated(t, s)
+	if len(files) != 2 {
+		t.Fatalf("rotated files = %v, want 2", files)
+	}
+	dir, _, _ := s.names()
+	for i, want := range [][]string{{line("1"), line("2")}, {line("3"), line("4")}} {
+		f := files[i]
+		if f.seq != i+1 || !strings.HasPrefix(f.name, "events-"+f.stamp+"-") || !strings.HasSuffix(f.name, ".jsonl") {
+			t.Errorf("rotated file %d is named %s, want events-<time>-%d.jsonl", i, f.name, i+1)
+		}
+		if got := readLines(t, filepath.Join(dir, f.name)); !slices.Equal(got, want) {
+			t.Errorf("%s holds %q, want %q", f.name, got, want)
+		}
+	}
+	if got := readLines(t, s.cfg.Path); !slices.Equal(got, []string{line("5")}) {
+		t.Errorf("%s holds %q, want the last event", s.cfg.Path, got)
+	}
+}
+
+func TestAgesExistingFileFromModificationTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		age     time.Duration
+		rotated bool
+	}{
+		{name: "old file", content: line("0") + "\n", age: 2 * time.Hour, rotated: true},
+		{name: "recent file", content: line("0") + "\n", age: time.Minute},
+		{name: "old empty file", age: 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.jsonl")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			modified := time.Now().Add(-tt.age)
+			if err := os.Chtimes(path, modified, modified); err != nil {
+				t.Fatal(err)
+			}
+
+			s := openSink(t, config.FileSinkConfig{Path: path, MaxAge: config.Duration(time.Hour), MaxFiles: -1})
+			s.maxSize = 0
+			send(t, s, "1")
+			if err := s.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			files := rotated(t, s)
+			if tt.rotated != (len(files) == 1) {
+				t.Fatalf("rotated files = %v, want rotated = %t", files, tt.rotated)
+			}
+			if tt.rotated {
+				if got := readLines(t, path); !slices.Equal(got, []string{line("1")}) {
+					t.Errorf("%s holds %q, want only the new event", path, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressesAndKeepsMaxFiles(t *testing.T) {
+	s := openSink(t, config.FileSinkConfig{Compress: true, MaxFiles: 2})
+	send(t, s, "1", "2", "3", "4", "5", "6", "7", "8", "9")
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	files := rotated(t, s)
+	if len(files) != 2 || files[0].seq != 3 || files[1].seq != 4 {
+		t.Fatalf("rotated files = %v, want the last 2 of 4 rotations", files)
+	}
+	dir, _, _ := s.names()
+	for i, want := range [][]string{{line("5"), line("6")}, {line("7"), line("8")}} {
+		f := files[i]
+		if !strings.HasSuffix(f.name, ".jsonl.gz") {
+			t.Errorf("rotated file %s is not compressed", f.name)
+			continue
+		}
+		path := filepath.Join(dir, f.name)
+		if got := readLines(t, path); !slices.Equal(got, want) {
+			t.Errorf("%s holds %q, want %q", f.name, got, want)
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("%s has mode %v, %v, want 0600", f.name, info.Mode().Perm(), err)
+		}
+	}
+	if info, err := os.Stat(s.cfg.Path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("%s has mode %v, %v, want 0600", s.cfg.Path, info.Mode().Perm(), err)
+	}
+}
+
+func TestParseRotated(t *testing.T) {
+	tests := []struct {
+		name  string
+		ok    bool
+		stamp string
+		seq   int
+	}{
+		{name: "events-20240101T120000.000Z-3.jsonl", ok: true, stamp: "20240101T120000.000Z", seq: 3},
+		{name: "events-20240101T120000.000Z-12.jsonl.gz", ok: true, stamp: "20240101T120000.000Z", seq: 12},
+		{name: "events-20240101T120000.000Z.jsonl", ok: true, stamp: "20240101T120000.000Z"},
+		{name: "events.jsonl"},
+		{name: "events-20240101T120000.000Z-x.jsonl"},
+		{name: "events-yesterday.jsonl"},
+		{name: "other-20240101T120000.000Z-1.jsonl"},
+	}
+	for _, tt := range tests {
+		f, ok := parseRotated(tt.name, "events-", ".jsonl")
+		if ok != tt.ok || f.stamp != tt.stamp || f.seq != tt.seq {
+			t.Errorf("parseRotated(%s) = %+v, %t, want stamp %q, seq %d, %t", tt.name, f, ok, tt.stamp, tt.seq, tt.ok)
+		}
+	}
+}
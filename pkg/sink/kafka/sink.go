@@ -0,0 +1,214 @@
+// Package kafka produces events as records to a Kafka topic.
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/event"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// keys are the event fields records can be keyed by.
+var keys = map[string]func(e *pb.PixieEvent) string{
+	"upid":           (*pb.PixieEvent).GetUpid,
+	"namespace":      (*pb.PixieEvent).GetKubernetesNamespace,
+	"service":        (*pb.PixieEvent).GetKubernetesService,
+	"remote_service": (*pb.PixieEvent).GetKubernetesRemoteService,
+	"protocol":       event.Protocol,
+	"source":         (*pb.PixieEvent).GetSource,
+}
+
+// Sink hands records to the client, which batches them per partition and
+// produces them in the background, retrying failed produce requests. Flush
+// waits for every record handed over to be produced. Sink is not safe for
+// concurrent use.
+type Sink struct {
+	cfg    config.KafkaSinkConfig
+	client *kgo.Client
+
+	mu     sync.Mutex
+	failed int   // Records not produced since the last flush
+	first  error // Why the first of them was not
+	err    error // Last flush error, reported by Health
+}
+
+func New(cfg config.KafkaSinkConfig) (*Sink, error) {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.DefaultProduceTopic(cfg.Topic),
+		kgo.ClientID(cfg.ClientID),
+		kgo.ProducerLinger(cfg.Linger.Duration()),
+		kgo.RecordDeliveryTimeout(cfg.Timeout.Duration()),
+		kgo.RecordRetries(cfg.Retry.MaxAttempts - 1),
+		kgo.RetryBackoffFn(cfg.Retry.Backoff.Delay),
+	}
+
+	switch cfg.Acks {
+	case config.AcksAll:
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	case config.AcksLeader:
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite())
+	case config.AcksNone:
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()), kgo.DisableIdempotentWrite())
+	default:
+		return nil, fmt.Errorf("unknown acks %q", cfg.Acks)
+	}
+
+	switch cfg.Partitioner {
+	case "hash":
+		opts = append(opts, kgo.RecordPartitioner(kgo.StickyKeyPartitioner(nil)))
+	case "round_robin":
+		opts = append(opts, kgo.RecordPartitioner(kgo.RoundRobinPartitioner()))
+	case "least_backup":
+		opts = append(opts, kgo.RecordPartitioner(kgo.LeastBackupPartitioner()))
+	default:
+		return nil, fmt.Errorf("unknown partitioner %q", cfg.Partitioner)
+	}
+
+	switch cfg.Compression {
+	case "none":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.NoCompression()))
+	case "gzip":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.GzipCompression()))
+	case "snappy":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.SnappyCompression()))
+	case "lz4":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.Lz4Compression()))
+	case "zstd":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.ZstdCompression()))
+	default:
+		return nil, fmt.Errorf("unknown compression %q", cfg.Compression)
+	}
+
+	if cfg.TLS.Enabled {
+		tlsConfig, err := cfg.TLS.ClientConfig(cfg.Brokers[0])
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.NextProtos = nil
+		tlsConfig.ServerName = cfg.TLS.ServerName // Set to each broker's host by the client when empty
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+
+	user, pass := cfg.SASL.Username, cfg.SASL.Password.Value()
+	switch cfg.SASL.Mechanism {
+	case "":
+	case "plain":
+		opts = append(opts, kgo.SASL(plain.Auth{User: user, Pass: pass}.AsMechanism()))
+	case "scram-sha-256":
+		opts = append(opts, kgo.SASL(scram.Auth{User: user, Pass: pass}.AsSha256Mechanism()))
+	case "scram-sha-512":
+		opts = append(opts, kgo.SASL(scram.Auth{User: user, Pass: pass}.AsSha512Mechanism()))
+	default:
+		return nil, fmt.Errorf("unknown SASL mechanism %q", cfg.SASL.Mechanism)
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{cfg: cfg, client: client}, nil
+}
+
+// Send hands the event to the client, blocking while the client buffers as
+// many records as it may. Records are timestamped when produced rather than
+// with the event's time, which the client would count against the timeout.
+func (s *Sink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	value, err := s.encode(e)
+	if err != nil {
+		return err
+	}
+	record := &kgo.Record{Value: value}
+	if s.cfg.Key != "" {
+		if key := keys[s.cfg.Key](e); key != "" {
+			record.Key = []byte(key)
+		}
+	}
+
+	s.client.Produce(ctx, record, s.produced)
+	return nil
+}
+
+// produced records why a record was not produced, called by the client once
+// the record is produced or has failed for good.
+func (s *Sink) produced(r *kgo.Record, err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failed == 0 {
+		s.first = err
+	}
+	s.failed++
+}
+
+// Flush waits for the records handed to the client to be produced, and
+// reports those that failed since the last flush.
+func (s *Sink) Flush(ctx context.Context) error {
+	flushErr := s.client.Flush(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if s.failed > 0 {
+		err = fmt.Errorf("%d records not produced to %s, e.g. %w", s.failed, s.cfg.Topic, s.first)
+		s.failed, s.first = 0, nil
+	}
+	s.err = errors.Join(flushErr, err)
+	return s.err
+}
+
+// Close flushes the records handed to the client before closing it.
+func (s *Sink) Close(ctx context.Context) error {
+	err := s.Flush(ctx)
+	s.client.Close()
+	return err
+}
+
+// Probe requests the metadata of the topic without creating it, which
+// connects and authenticates to a broker.
+func (s *Sink) Probe(ctx context.Context) error {
+	topic := kmsg.NewMetadataRequestTopic()
+	topic.Topic = kmsg.StringPtr(s.cfg.Topic)
+	req := kmsg.NewPtrMetadataRequest()
+	req.Topics = append(req.Topics, topic)
+	req.AllowAutoTopicCreation = false
+
+	resp, err := req.RequestWith(ctx, s.client)
+	if err != nil {
+		return err
+	}
+	for _, t := range resp.Topics {
+		if err := kerr.ErrorForCode(t.ErrorCode); err != nil {
+			return fmt.Errorf("topic %s: %w", s.cfg.Topic, err)
+		}
+	}
+	return nil
+}
+
+func (s *Sink) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// encode serializes an event in the configured format.
+func (s *Sink) encode(e *pb.PixieEvent) ([]byte, error) {
+	if s.cfg.Format == config.FormatJSON {
+		return protojson.Marshal(e)
+	}
+	return proto.Marshal(e)
+}
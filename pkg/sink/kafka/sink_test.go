@@ -0,0 +1,230 @@
+package kafka
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+const topic = "observer-events"
+
+// broker is an in-memory Kafka cluster recording the acks of the produce
+// requests it receives and whether the producer asked for an ID, which it
+// does to write idempotently.
+type broker struct {
+	*kfake.Cluster
+
+	mu         sync.Mutex
+	acks       []int16
+	producerID bool
+}
+
+func newBroker(t *testing.T) *broker {
+	t.Helper()
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(3, topic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cluster.Close)
+
+	b := &broker{Cluster: cluster}
+	cluster.ControlKey(int16(kmsg.Produce), func(req kmsg.Request) (kmsg.Response, error, bool) {
+		cluster.KeepControl()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.acks = append(b.acks, req.(*kmsg.ProduceRequest).Acks)
+		return nil, nil, false
+	})
+	cluster.ControlKey(int16(kmsg.InitProducerID), func(kmsg.Request) (kmsg.Response, error, bool) {
+		cluster.KeepControl()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.producerID = true
+		return nil, nil, false
+	})
+	return b
+}
+
+// consume reads n records from the topic.
+func (b *broker) consume(t *testing.T, n int) []*kgo.Record {
+	t.Helper()
+	client, err := kgo.NewClient(kgo.SeedBrokers(b.ListenAddrs()...), kgo.ConsumeTopics(topic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var records []*kgo.Record
+	for len(records) < n {
+		fetches := client.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("consumed %d records, want %d", len(records), n)
+		}
+		records = append(records, fetches.Records()...)
+	}
+	return records
+}
+
+func testConfig(brokers []string) config.KafkaSinkConfig {
+	return config.KafkaSinkConfig{
+		Brokers:     brokers,
+		Topic:       topic,
+		ClientID:    "observer",
+		Format:      config.FormatProtobuf,
+		Acks:        config.AcksAll,
+		Partitioner: "hash",
+		Compression: "snappy",
+		Timeout:     config.Duration(10 * time.Second),
+		Retry: config.RetryConfig{
+			MaxAttempts: 3,
+			Backoff:     config.BackoffConfig{Base: config.Duration(10 * time.Millisecond), Max: config.Duration(10 * time.Millisecond)},
+		},
+	}
+}
+
+func TestProduce(t *testing.T) {
+	events := []*pb.PixieEvent{
+		{Upid: "1", KubernetesService: "checkout", Latency: 1200},
+		{Upid: "2", KubernetesService: "cart", Latency: 300},
+		{Upid: "3"},
+	}
+	tests := []struct {
+		name       string
+		format     string
+		acks       string
+		wantAcks   int16
+		idempotent bool
+		decode     func([]byte, *pb.PixieEvent) error
+	}{
+		{name: "protobuf idempotently", format: config.FormatProtobuf, acks: config.AcksAll, wantAcks: -1, idempotent: true, decode: func(b []byte, e *pb.PixieEvent) error { return proto.Unmarshal(b, e) }},
+		{name: "json to the leader", format: config.FormatJSON, acks: config.AcksLeader, wantAcks: 1, decode: func(b []byte, e *pb.PixieEvent) error { return protojson.Unmarshal(b, e) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBroker(t)
+			cfg := testConfig(b.ListenAddrs())
+			cfg.Format = tt.format
+			cfg.Acks = tt.acks
+			cfg.Key = "service"
+			s, err := New(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, e := range events {
+				if err := s.Send(context.Background(), e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Close(context.Background()); err != nil {
+				t.Fatalf("Close returned %v, want every record produced", err)
+			}
+
+			records := b.consume(t, len(events))
+			byUpid := map[string]*kgo.Record{}
+			for _, r := range records {
+				var e pb.PixieEvent
+				if err := tt.decode(r.Value, &e); err != nil {
+					t.Fatalf("record %q is not a %s event: %v", r.Value, tt.format, err)
+				}
+				byUpid[e.Upid] = r
+			}
+			for _, e := range events {
+				r, ok := byUpid[e.Upid]
+				if !ok {
+					t.Errorf("event %s was not produced", e.Upid)
+					continue
+				}
+				var key []byte
+				if e.KubernetesService != "" {
+					key = []byte(e.KubernetesService)
+				}
+				if string(r.Key) != string(key) || (r.Key == nil) != (key == nil) {
+					t.Errorf("event %s is keyed by %q, want %q", e.Upid, r.Key, key)
+				}
+			}
+
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if len(b.acks) == 0 {
+				t.Fatal("no produce requests")
+			}
+			for _, acks := range b.acks {
+				if acks != tt.wantAcks {
+					t.Errorf("produce request acks = %d, want %d", acks, tt.wantAcks)
+				}
+			}
+			if b.producerID != tt.idempotent {
+				t.Errorf("producer ID requested = %t, want %t", b.producerID, tt.idempotent)
+			}
+		})
+	}
+}
+
+func TestFlushReportsRecordsNotProduced(t *testing.T) {
+	b := newBroker(t)
+	cfg := testConfig(b.ListenAddrs())
+	cfg.Topic = "missing"
+	cfg.Timeout = config.Duration(time.Second)
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(context.Background())
+
+	for i := 0; i < 2; i++ {
+		if err := s.Send(context.Background(), &pb.PixieEvent{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = s.Flush(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "2 records not produced to missing") {
+		t.Fatalf("Flush returned %v, want both records reported", err)
+	}
+	if s.Health() == nil {
+		t.Error("Health returned nil after records were not produced")
+	}
+
+	if err := s.Flush(context.Background()); err != nil {
+		t.Fatalf("second Flush returned %v, want nil with nothing sent since", err)
+	}
+	if s.Health() != nil {
+		t.Errorf("Health returned %v after a clean flush", s.Health())
+	}
+}
+
+func TestProbe(t *testing.T) {
+	b := newBroker(t)
+	for _, tt := range []struct {
+		topic string
+		fails bool
+	}{
+		{topic: topic},
+		{topic: "missing", fails: true},
+	} {
+		cfg := testConfig(b.ListenAddrs())
+		cfg.Topic = tt.topic
+		s, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.Probe(context.Background())
+		s.Close(context.Background())
+		if (err != nil) != tt.fails {
+			t.Errorf("Probe of topic %s returned %v, want failed = %t", tt.topic, err, tt.fails)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package loki
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// pushRequest encodes the streams as the logproto.PushRequest of the push API:
+//
+//	message PushRequest { repeated StreamAdapter streams = 1; }
+//	message StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; }
+//	message EntryAdapter { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+func pushRequest(streams []*stream) []byte {
+	var req []byte
+	for _, st := range streams {
+		var msg []byte
+		msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+		msg = protowire.AppendString(msg, st.labels)
+		for _, e := range st.entries {
+			var timestamp []byte
+			timestamp = protowire.AppendTag(timestamp, 1, protowire.VarintType)
+			timestamp = protowire.AppendVarint(timestamp, uint64(e.at.Unix()))
+			timestamp = protowire.AppendTag(timestamp, 2, protowire.VarintType)
+			timestamp = protowire.AppendVarint(timestamp, uint64(e.at.Nanosecond()))
+
+			var entry []byte
+			entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+			entry = protowire.AppendBytes(entry, timestamp)
+			entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+			entry = protowire.AppendString(entry, e.line)
+
+			msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+			msg = protowire.AppendBytes(msg, entry)
+		}
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, msg)
+	}
+	return req
+}
+
+// logfmt renders the set fields of an event as key=value pairs named after
+// the protobuf fields, followed by those of its protocol data, e.g.
+// upid=... latency=1200 req_method=GET resp_status=200.
+func logfmt(e *pb.PixieEvent, omit map[string]bool) string {
+	var b strings.Builder
+	var appendFields func(m protoreflect.Message)
+	appendFields = func(m protoreflect.Message) {
+		m.Range(func(field protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			name := string(field.Name())
+			switch {
+			case omit[name]:
+			case field.Kind() == protoreflect.MessageKind:
+				appendFields(v.Message())
+			default:
+				if b.Len() > 0 {
+					b.WriteByte(' ')
+				}
+				b.WriteString(name)
+				b.WriteByte('=')
+				b.WriteString(logfmtValue(v.String()))
+			}
+			return true
+		})
+	}
+	appendFields(e.ProtoReflect())
+	return b.String()
+}
+
+// logfmtValue quotes values that are empty or contain spaces, quotes, equals
+// signs or unprintable characters.
+func logfmtValue(v string) string {
+	if v == "" || strings.IndexFunc(v, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == '\\' || !unicode.IsPrint(r)
+	}) >= 0 {
+		return strconv.Quote(v)
+	}
+	return v
+}
@@ -0,0 +1,253 @@
+// Package loki pushes events to the Grafana Loki push API as log lines.
+package loki
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/event"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+	"orbservability/observer/pkg/sink/retry"
+
+	"github.com/golang/snappy"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// labels are the event fields a stream can be labelled with, see config.LokiLabels.
+var labels = map[string]func(e *pb.PixieEvent) string{
+	"source":    (*pb.PixieEvent).GetSource,
+	"namespace": (*pb.PixieEvent).GetKubernetesNamespace,
+	"service":   (*pb.PixieEvent).GetKubernetesService,
+	"protocol":  event.Protocol,
+	"side":      event.Side,
+}
+
+// Sink batches events into streams until the lines reach the configured
+// size or the sink is flushed. Sink is not safe for concurrent use.
+type Sink struct {
+	cfg     config.LokiSinkConfig
+	pushURL string
+	headers map[string]string
+	client  *http.Client
+	omit    map[string]bool // Fields left out of logfmt lines as they are stream labels
+
+	streams map[string]*stream // By label set
+	size    int                // Bytes of the batched lines
+
+	mu  sync.Mutex
+	err error // Last push error, reported by Health
+}
+
+// stream is the batched entries of one label set.
+type stream struct {
+	labels  string
+	entries []entry
+}
+
+type entry struct {
+	at   time.Time
+	line string
+}
+
+func New(cfg config.LokiSinkConfig) (*Sink, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.TLS.Enabled {
+		tlsConfig, err := cfg.TLS.HTTPClientConfig(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	s := &Sink{
+		cfg:     cfg,
+		pushURL: strings.TrimSuffix(cfg.URL, "/") + "/loki/api/v1/push",
+		headers: map[string]string{},
+		client:  &http.Client{Transport: transport},
+		omit:    map[string]bool{"time": true},
+		streams: map[string]*stream{},
+	}
+	for name, value := range cfg.Headers {
+		s.headers[name] = value.Value()
+	}
+	if cfg.TenantID != "" {
+		s.headers["X-Scope-OrgID"] = cfg.TenantID
+	}
+	for _, label := range cfg.Labels {
+		switch label {
+		case "source":
+			s.omit["source"] = true
+		case "namespace":
+			s.omit["kubernetes_namespace"] = true
+		case "service":
+			s.omit["kubernetes_service"] = true
+		}
+	}
+	return s, nil
+}
+
+func (s *Sink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	line, err := s.line(e)
+	if err != nil {
+		return err
+	}
+	at, ok := event.Time(e)
+	if !ok {
+		at = time.Now()
+	}
+
+	key := s.labels(e)
+	st, found := s.streams[key]
+	if !found {
+		st = &stream{labels: key}
+		s.streams[key] = st
+	}
+	st.entries = append(st.entries, entry{at: at, line: line})
+	s.size += len(line)
+	if s.size < s.cfg.BatchBytes {
+		return nil
+	}
+	return s.Flush(ctx)
+}
+
+// Flush pushes the batch. A batch that still fails after its retries is
+// dropped, and entries rejected by Loki for being out of order are logged
+// and dropped, as retrying them would duplicate the accepted entries.
+func (s *Sink) Flush(ctx context.Context) error {
+	if len(s.streams) == 0 {
+		return nil
+	}
+	streams := make([]*stream, 0, len(s.streams))
+	for _, st := range s.streams {
+		// Loki rejects entries older than the last one of their stream
+		sort.SliceStable(st.entries, func(i, j int) bool { return st.entries[i].at.Before(st.entries[j].at) })
+		streams = append(streams, st)
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i].labels < streams[j].labels })
+	body := snappy.Encode(nil, pushRequest(streams))
+	s.streams = map[string]*stream{}
+	s.size = 0
+
+	err := retry.Do(ctx, s.cfg.Retry, func(ctx context.Context) error {
+		return s.push(ctx, body)
+	}, retry.HTTP)
+	if outOfOrder(err) {
+		log.Warn().Err(err).Str("url", s.pushURL).Msg("Loki rejected out of order entries")
+		err = nil
+	}
+
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+	return err
+}
+
+func (s *Sink) Close(ctx context.Context) error {
+	err := s.Flush(ctx)
+	s.client.CloseIdleConnections()
+	return err
+}
+
+func (s *Sink) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Probe pushes a request without any stream, which Loki accepts without
+// storing anything once it authenticated the tenant.
+func (s *Sink) Probe(ctx context.Context) error {
+	return s.push(ctx, snappy.Encode(nil, pushRequest(nil)))
+}
+
+// push sends one snappy compressed push request within the configured timeout.
+func (s *Sink) push(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.pushURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := retry.CheckResponse(resp); err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20)) // Lets the connection be reused
+	return nil
+}
+
+// labels renders the stream labels of an event, e.g. {namespace="default", protocol="http"},
+// sorted by name and leaving out empty values.
+func (s *Sink) labels(e *pb.PixieEvent) string {
+	values := map[string]string{}
+	for name, value := range s.cfg.StaticLabels {
+		values[name] = value
+	}
+	for _, name := range s.cfg.Labels {
+		if value := labels[name](e); value != "" {
+			values[name] = value
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteByte('"')
+		labelValue.WriteString(&b, values[name])
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// labelValue escapes the backslashes, quotes and line breaks of a label
+// value, leaving any other character, including non-ASCII ones, as is.
+var labelValue = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (s *Sink) line(e *pb.PixieEvent) (string, error) {
+	if s.cfg.Format == config.FormatJSON {
+		line, err := protojson.Marshal(e)
+		return string(line), err
+	}
+	return logfmt(e, s.omit), nil
+}
+
+// outOfOrder reports whether Loki rejected entries for being older than
+// those it already has.
+func outOfOrder(err error) bool {
+	var statusErr *retry.StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusBadRequest {
+		return false
+	}
+	return strings.Contains(statusErr.Message, "out of order") || strings.Contains(statusErr.Message, "too far behind")
+}
@@ -0,0 +1,298 @@
+package loki
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// receiver is a Loki push endpoint answering each request with the next of
+// responses, and 204 once they run out.
+type receiver struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses []response
+	requests  []request
+}
+
+type response struct {
+	status int
+	body   string // Sent as text/plain, as Loki explains rejections
+}
+
+type request struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, responses ...response) *receiver {
+	r := &receiver{responses: responses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, request{path: req.URL.Path, header: req.Header, body: body})
+		if len(r.responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		resp := r.responses[0]
+		r.responses = r.responses[1:]
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(resp.status)
+		io.WriteString(w, resp.body)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() []request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]request(nil), r.requests...)
+}
+
+func testConfig(url string) config.LokiSinkConfig {
+	return config.LokiSinkConfig{
+		URL:        url,
+		Labels:     []string{"namespace", "protocol"},
+		Format:     config.FormatLogfmt,
+		Timeout:    config.Duration(5 * time.Second),
+		BatchBytes: 1 << 20,
+		Retry: config.RetryConfig{
+			MaxAttempts: 3,
+			Backoff:     config.BackoffConfig{Base: config.Duration(time.Millisecond), Max: config.Duration(time.Millisecond)},
+		},
+	}
+}
+
+func sendAll(t *testing.T, s *Sink, events ...*pb.PixieEvent) error {
+	t.Helper()
+	for _, e := range events {
+		if err := s.Send(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s.Flush(context.Background())
+}
+
+// pushedStream is a stream decoded from a push request.
+type pushedStream struct {
+	labels  string
+	entries []pushedEntry
+}
+
+type pushedEntry struct {
+	at   time.Time
+	line string
+}
+
+// decodePush decompresses and decodes the push request body, see pushRequest.
+func decodePush(t *testing.T, body []byte) []pushedStream {
+	t.Helper()
+	decoded, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("body is not snappy compressed: %v", err)
+	}
+	var streams []pushedStream
+	for _, msg := range fields(t, decoded, 1) {
+		var st pushedStream
+		for _, label := range fields(t, msg, 1) {
+			st.labels = string(label)
+		}
+		for _, entry := range fields(t, msg, 2) {
+			var e pushedEntry
+			for _, line := range fields(t, entry, 2) {
+				e.line = string(line)
+			}
+			for _, timestamp := range fields(t, entry, 1) {
+				var seconds, nanos uint64
+				for len(timestamp) > 0 {
+					num, _, n := protowire.ConsumeTag(timestamp)
+					if n < 0 {
+						t.Fatalf("malformed timestamp %q", timestamp)
+					}
+					v, m := protowire.ConsumeVarint(timestamp[n:])
+					if m < 0 {
+						t.Fatalf("malformed timestamp %q", timestamp)
+					}
+					if num == 1 {
+						seconds = v
+					} else {
+						nanos = v
+					}
+					timestamp = timestamp[n+m:]
+				}
+				e.at = time.Unix(int64(seconds), int64(nanos))
+			}
+			st.entries = append(st.entries, e)
+		}
+		streams = append(streams, st)
+	}
+	return streams
+}
+
+// fields returns the length delimited fields numbered num of a message.
+func fields(t *testing.T, msg []byte, num protowire.Number) [][]byte {
+	t.Helper()
+	var values [][]byte
+	for len(msg) > 0 {
+		n, typ, length := protowire.ConsumeTag(msg)
+		if length < 0 || typ != protowire.BytesType {
+			t.Fatalf("malformed message %q", msg)
+		}
+		msg = msg[length:]
+		value, length := protowire.ConsumeBytes(msg)
+		if length < 0 {
+			t.Fatalf("malformed message %q", msg)
+		}
+		msg = msg[length:]
+		if n == num {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func httpEvent(at time.Time, namespace string, upid string) *pb.PixieEvent {
+	return &pb.PixieEvent{
+		Time:                at.Format(time.RFC3339Nano),
+		Upid:                upid,
+		KubernetesNamespace: namespace,
+		ProtocolData:        &pb.PixieEvent_Http{Http: &pb.HypertextTransferProtocol{ReqMethod: "GET"}},
+	}
+}
+
+func TestPushesStreamsByLabels(t *testing.T) {
+	r := newReceiver(t)
+	cfg := testConfig(r.URL + "/")
+	cfg.StaticLabels = map[string]string{"cluster": "prod"}
+	cfg.TenantID = "team"
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	err = sendAll(t, s,
+		httpEvent(start.Add(2*time.Second), "shop", "2"),
+		httpEvent(start.Add(time.Second), "default", "3"),
+		httpEvent(start, "shop", "1"),
+		&pb.PixieEvent{Time: start.Format(time.RFC3339Nano), Upid: "4"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := r.received()
+	if len(requests) != 1 {
+		t.Fatalf("%d requests, want the batch pushed at once", len(requests))
+	}
+	req := requests[0]
+	if req.path != "/loki/api/v1/push" {
+		t.Errorf("pushed to %s, want /loki/api/v1/push", req.path)
+	}
+	if got := req.header.Get("Content-Type"); got != "application/x-protobuf" {
+		t.Errorf("Content-Type = %q, want application/x-protobuf", got)
+	}
+	if got := req.header.Get("X-Scope-OrgID"); got != "team" {
+		t.Errorf("X-Scope-OrgID = %q, want the tenant", got)
+	}
+
+	want := []pushedStream{
+		{labels: `{cluster="prod", namespace="default", protocol="http"}`, entries: []pushedEntry{
+			{at: start.Add(time.Second), line: "upid=3 req_method=GET"},
+		}},
+		{labels: `{cluster="prod", namespace="shop", protocol="http"}`, entries: []pushedEntry{
+			{at: start, line: "upid=1 req_method=GET"},
+			{at: start.Add(2 * time.Second), line: "upid=2 req_method=GET"},
+		}},
+		{labels: `{cluster="prod"}`, entries: []pushedEntry{{at: start, line: "upid=4"}}},
+	}
+	got := decodePush(t, req.body)
+	if len(got) != len(want) {
+		t.Fatalf("pushed streams %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].labels != want[i].labels || !slices.EqualFunc(got[i].entries, want[i].entries, func(a, b pushedEntry) bool {
+			return a.at.Equal(b.at) && a.line == b.line
+		}) {
+			t.Errorf("pushed stream %+v, want %+v", got[i], want[i])
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	tests := []struct {
+		namespace string
+		want      string
+	}{
+		{namespace: "shop", want: `{namespace="shop"}`},
+		{namespace: "", want: `{}`},
+		{namespace: `a"b\c`, want: `{namespace="a\"b\\c"}`},
+		{namespace: "a\nb", want: `{namespace="a\nb"}`},
+		{namespace: "café\tbar", want: "{namespace=\"café\tbar\"}"},
+	}
+	s, err := New(config.LokiSinkConfig{Labels: []string{"namespace"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if got := s.labels(&pb.PixieEvent{KubernetesNamespace: tt.namespace}); got != tt.want {
+			t.Errorf("labels of namespace %q = %s, want %s", tt.namespace, got, tt.want)
+		}
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []response
+		requests  int
+		fails     bool
+	}{
+		{name: "server error", responses: []response{{status: http.StatusServiceUnavailable}}, requests: 2},
+		{name: "rate limited", responses: []response{{status: http.StatusTooManyRequests}}, requests: 2},
+		{name: "out of order", responses: []response{{status: http.StatusBadRequest, body: "entry with timestamp 2024-01-01 12:00:00 ignored, reason: 'entry out of order'"}}, requests: 1},
+		{name: "too far behind", responses: []response{{status: http.StatusBadRequest, body: "entry too far behind, oldest acceptable timestamp is: 2024-01-01T12:00:00Z"}}, requests: 1},
+		{name: "bad request", responses: []response{{status: http.StatusBadRequest, body: "error parsing labels"}}, requests: 1, fails: true},
+		{name: "retries exhausted", responses: []response{{status: http.StatusBadGateway}, {status: http.StatusBadGateway}, {status: http.StatusBadGateway}}, requests: 3, fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReceiver(t, tt.responses...)
+			s, err := New(testConfig(r.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = sendAll(t, s, httpEvent(time.Now(), "shop", "1"))
+			if (err != nil) != tt.fails {
+				t.Fatalf("Flush returned %v, want failed = %t", err, tt.fails)
+			}
+			if (s.Health() != nil) != tt.fails {
+				t.Errorf("Health returned %v, want failed = %t", s.Health(), tt.fails)
+			}
+			if n := len(r.received()); n != tt.requests {
+				t.Fatalf("%d requests, want %d", n, tt.requests)
+			}
+
+			// The batch is dropped either way rather than pushed again
+			if err := s.Flush(context.Background()); err != nil || len(r.received()) != tt.requests {
+				t.Errorf("Flush of an empty batch returned %v after %d requests, want nothing pushed", err, len(r.received()))
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package opensearch
+
+import (
+	"encoding/json"
+	"strings"
+
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// timestampField holds the time of the event as a date, added to every
+// document next to the protojson fields.
+const timestampField = "@timestamp"
+
+// payloads are the string fields holding request and response data, mapped
+// as full text rather than exact values.
+var payloads = []string{"body", "header", "headers", "args", "msg", "message", "req", "resp"}
+
+// indexTemplate returns the body of a composable index template mapping the
+// fields of the protojson encoded events in indices matching pattern.
+func indexTemplate(pattern string) ([]byte, error) {
+	properties := mapping((&pb.PixieEvent{}).ProtoReflect().Descriptor())
+	properties[timestampField] = map[string]any{"type": "date"}
+	return json.Marshal(map[string]any{
+		"index_patterns": []string{pattern},
+		"template": map[string]any{
+			"mappings": map[string]any{
+				"dynamic":    false,
+				"properties": properties,
+			},
+		},
+	})
+}
+
+// mapping maps the fields of a message by their protojson names.
+func mapping(message protoreflect.MessageDescriptor) map[string]any {
+	properties := map[string]any{}
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field.Name() == "api_key" {
+			continue // Only set on the events sent to the gateway
+		}
+		switch field.Kind() {
+		case protoreflect.StringKind:
+			if isPayload(string(field.Name())) {
+				properties[field.JSONName()] = map[string]any{"type": "text"}
+			} else {
+				properties[field.JSONName()] = map[string]any{"type": "keyword", "ignore_above": 1024}
+			}
+		case protoreflect.BoolKind:
+			properties[field.JSONName()] = map[string]any{"type": "boolean"}
+		case protoreflect.Int32Kind, protoreflect.Int64Kind, protoreflect.Sint32Kind, protoreflect.Sint64Kind,
+			protoreflect.Uint32Kind, protoreflect.Uint64Kind:
+			properties[field.JSONName()] = map[string]any{"type": "long"}
+		case protoreflect.FloatKind, protoreflect.DoubleKind:
+			properties[field.JSONName()] = map[string]any{"type": "double"}
+		case protoreflect.MessageKind:
+			properties[field.JSONName()] = map[string]any{"properties": mapping(field.Message())}
+		}
+	}
+	return properties
+}
+
+// isPayload reports whether a field name is, or ends in, one of the payloads.
+func isPayload(name string) bool {
+	for _, payload := range payloads {
+		if name == payload || strings.HasSuffix(name, "_"+payload) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+// Package opensearch indexes events into OpenSearch or Elasticsearch with
+// the _bulk API.
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/event"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+	"orbservability/observer/pkg/sink/retry"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Sink indexes events in batches of up to the configured size, or sooner on
+// flush. Sink is not safe for concurrent use.
+type Sink struct {
+	cfg     config.OpenSearchSinkConfig
+	baseURL string
+	headers map[string]string
+	client  *http.Client
+	batch   []document
+
+	mu  sync.Mutex
+	err error // Last bulk error, reported by Health
+}
+
+// document is an event encoded for the bulk request, with its action line.
+type document struct {
+	action []byte
+	source []byte
+}
+
+// New installs the index template, if one is configured, before returning.
+func New(cfg config.OpenSearchSinkConfig) (*Sink, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.TLS.Enabled {
+		tlsConfig, err := cfg.TLS.HTTPClientConfig(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	s := &Sink{
+		cfg:     cfg,
+		baseURL: strings.TrimSuffix(cfg.URL, "/"),
+		headers: map[string]string{},
+		client:  &http.Client{Transport: transport},
+	}
+	for name, value := range cfg.Headers {
+		s.headers[name] = value.Value()
+	}
+
+	if cfg.Template != "" {
+		template, err := indexTemplate(cfg.IndexPattern())
+		if err != nil {
+			return nil, err
+		}
+		err = retry.Do(context.Background(), cfg.Retry, func(ctx context.Context) error {
+			_, err := s.request(ctx, http.MethodPut, "/_index_template/"+cfg.Template, "application/json", template)
+			return err
+		}, retry.HTTP)
+		if err != nil {
+			return nil, fmt.Errorf("installing index template %s: %w", cfg.Template, err)
+		}
+		log.Debug().Str("template", cfg.Template).Str("pattern", cfg.IndexPattern()).Msg("Installed index template")
+	}
+	return s, nil
+}
+
+func (s *Sink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	doc, err := s.encode(e)
+	if err != nil {
+		return err
+	}
+	s.batch = append(s.batch, doc)
+	if len(s.batch) < s.cfg.BatchSize {
+		return nil
+	}
+	return s.Flush(ctx)
+}
+
+// Flush indexes the batch. Documents rejected with 429 or 5xx are retried on
+// their own, while documents rejected for other reasons, such as a mapping
+// conflict, are dropped and reported in the returned error.
+func (s *Sink) Flush(ctx context.Context) error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	pending := s.batch
+	s.batch = nil
+
+	var rejected int
+	var reason string
+	err := retry.Do(ctx, s.cfg.Retry, func(ctx context.Context) error {
+		retryable, dropped, err := s.bulk(ctx, pending)
+		if err != nil {
+			return err
+		}
+		if len(dropped) > 0 {
+			rejected += len(dropped)
+			reason = dropped[0]
+		}
+		pending = retryable
+		if len(pending) > 0 {
+			return &itemsError{count: len(pending)}
+		}
+		return nil
+	}, func(err error) (time.Duration, bool) {
+		var itemsErr *itemsError
+		if errors.As(err, &itemsErr) {
+			return 0, true
+		}
+		return retry.HTTP(err)
+	})
+	if err == nil && rejected > 0 {
+		err = fmt.Errorf("%d documents rejected, e.g. %s", rejected, reason)
+	}
+
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+	return err
+}
+
+// Probe requests the cluster's information, which needs the credentials to
+// be accepted.
+func (s *Sink) Probe(ctx context.Context) error {
+	_, err := s.request(ctx, http.MethodGet, "/", "application/json", nil)
+	return err
+}
+
+func (s *Sink) Close(ctx context.Context) error {
+	err := s.Flush(ctx)
+	s.client.CloseIdleConnections()
+	return err
+}
+
+func (s *Sink) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// encode renders the action line and the protojson document of an event,
+// with the event's time added as a date.
+func (s *Sink) encode(e *pb.PixieEvent) (document, error) {
+	at, ok := event.Time(e)
+	if !ok {
+		at = time.Now()
+	}
+	action, err := json.Marshal(map[string]any{"index": map[string]string{"_index": s.cfg.IndexName(at)}})
+	if err != nil {
+		return document{}, err
+	}
+	source, err := protojson.Marshal(e)
+	if err != nil {
+		return document{}, err
+	}
+
+	// Splice the timestamp in as the first field of the protojson object
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{%q:%q`, timestampField, at.UTC().Format(time.RFC3339Nano))
+	if fields := bytes.TrimSpace(source[1:]); len(fields) > 1 {
+		buf.WriteByte(',')
+	}
+	buf.Write(bytes.TrimSpace(source[1:]))
+	return document{action: action, source: buf.Bytes()}, nil
+}
+
+// bulkResponse is the part of a _bulk response describing each item.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// bulk indexes docs, returning the documents worth retrying and the reasons
+// the others were rejected for.
+func (s *Sink) bulk(ctx context.Context, docs []document) ([]document, []string, error) {
+	var body bytes.Buffer
+	for _, doc := range docs {
+		body.Write(doc.action)
+		body.WriteByte('\n')
+		body.Write(doc.source)
+		body.WriteByte('\n')
+	}
+	respBody, err := s.request(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resp bulkResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, nil, fmt.Errorf("decoding bulk response: %w", err)
+	}
+	if !resp.Errors {
+		return nil, nil, nil
+	}
+	if len(resp.Items) != len(docs) {
+		return nil, nil, fmt.Errorf("bulk response has %d items for %d documents", len(resp.Items), len(docs))
+	}
+
+	var retryable []document
+	var dropped []string
+	for i, item := range resp.Items {
+		for _, result := range item { // A single action, "index"
+			switch {
+			case result.Status/100 == 2:
+			case result.Status == http.StatusTooManyRequests || result.Status >= 500:
+				retryable = append(retryable, docs[i])
+			default:
+				dropped = append(dropped, fmt.Sprintf("%d %s: %s", result.Status, result.Error.Type, result.Error.Reason))
+			}
+		}
+	}
+	return retryable, dropped, nil
+}
+
+// request sends one request within the configured timeout, returning the response body.
+func (s *Sink) request(ctx context.Context, method string, path string, contentType string, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+	if s.cfg.Username != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password.Value())
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := retry.CheckResponse(resp); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+}
+
+// itemsError is a bulk request with documents left to retry.
+type itemsError struct {
+	count int
+}
+
+func (e *itemsError) Error() string {
+	return fmt.Sprintf("%d documents rejected with 429 or 5xx", e.count)
+}
@@ -0,0 +1,252 @@
+package opensearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+// cluster stands in for the _bulk and _index_template APIs. Documents whose
+// upid is "conflict" are rejected with 400, and those whose upid is "busy"
+// with 429 the first time they are indexed; the others are created.
+type cluster struct {
+	*httptest.Server
+	t *testing.T
+
+	mu        sync.Mutex
+	bulks     [][]string // upids of the documents of each bulk request
+	indices   []string
+	busy      int // 429s answered so far
+	templates []templateRequest
+	failPuts  int // Template requests to answer with 503
+}
+
+type templateRequest struct {
+	path   string
+	header http.Header
+	body   map[string]any
+}
+
+func newCluster(t *testing.T) *cluster {
+	c := &cluster{t: t}
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serve))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *cluster) serve(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/_index_template/") {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			c.t.Errorf("template body: %v", err)
+		}
+		c.templates = append(c.templates, templateRequest{path: r.URL.Path, header: r.Header, body: body})
+		if len(c.templates) <= c.failPuts {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"acknowledged":true}`))
+		return
+	}
+	if r.Method != http.MethodPost || r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+		c.t.Errorf("unexpected %s %s with Content-Type %q", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	type item struct {
+		Status int            `json:"status"`
+		Error  map[string]any `json:"error,omitempty"`
+	}
+	var upids []string
+	var items []map[string]item
+	errors := false
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action struct {
+			Index struct {
+				Index string `json:"_index"`
+			} `json:"index"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil || !scanner.Scan() {
+			c.t.Errorf("bad action line %q: %v", scanner.Text(), err)
+			return
+		}
+		var source map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &source); err != nil {
+			c.t.Errorf("bad source line %q: %v", scanner.Text(), err)
+			return
+		}
+		if _, ok := source[timestampField]; !ok {
+			c.t.Errorf("document %s has no %s", scanner.Text(), timestampField)
+		}
+		upid, _ := source["upid"].(string)
+		upids = append(upids, upid)
+		c.indices = append(c.indices, action.Index.Index)
+
+		result := item{Status: http.StatusCreated}
+		switch {
+		case upid == "conflict":
+			result = item{Status: http.StatusBadRequest, Error: map[string]any{"type": "mapper_parsing_exception", "reason": "failed to parse field [latency]"}}
+		case upid == "busy" && c.busy == 0:
+			c.busy++
+			result = item{Status: http.StatusTooManyRequests, Error: map[string]any{"type": "es_rejected_execution_exception", "reason": "queue full"}}
+		}
+		errors = errors || result.Status/100 != 2
+		items = append(items, map[string]item{"index": result})
+	}
+	c.bulks = append(c.bulks, upids)
+	json.NewEncoder(w).Encode(map[string]any{"took": 1, "errors": errors, "items": items})
+}
+
+func testConfig(url string) config.OpenSearchSinkConfig {
+	return config.OpenSearchSinkConfig{
+		URL:       url + "/",
+		Index:     "observer-{2006.01.02}",
+		Timeout:   config.Duration(5 * time.Second),
+		BatchSize: 100,
+		Retry: config.RetryConfig{
+			MaxAttempts: 3,
+			Backoff:     config.BackoffConfig{Base: config.Duration(time.Millisecond), Max: config.Duration(time.Millisecond)},
+		},
+	}
+}
+
+func TestFlushRetriesOnlyRejectedWith429(t *testing.T) {
+	c := newCluster(t)
+	s, err := New(testConfig(c.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, upid := range []string{"created", "busy", "conflict", "also created"} {
+		if err := s.Send(context.Background(), &pb.PixieEvent{Upid: upid, Time: "2024-03-01T12:00:00Z"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = s.Flush(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "1 documents rejected") || !strings.Contains(err.Error(), "400 mapper_parsing_exception: failed to parse field [latency]") {
+		t.Fatalf("Flush returned %v, want the 400 reported", err)
+	}
+	if s.Health() == nil {
+		t.Error("Health returned nil after documents were rejected")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.bulks) != 2 {
+		t.Fatalf("bulk requests = %q, want 2", c.bulks)
+	}
+	if got := strings.Join(c.bulks[0], ","); got != "created,busy,conflict,also created" {
+		t.Errorf("first bulk request indexed %s, want every document", got)
+	}
+	if got := strings.Join(c.bulks[1], ","); got != "busy" {
+		t.Errorf("second bulk request indexed %s, want only the document rejected with 429", got)
+	}
+	if c.indices[0] != "observer-2024.03.01" {
+		t.Errorf("index = %q, want observer-2024.03.01 from the event time", c.indices[0])
+	}
+}
+
+func TestFlushSucceedsOnceEveryDocumentIsIndexed(t *testing.T) {
+	c := newCluster(t)
+	s, err := New(testConfig(c.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Send(context.Background(), &pb.PixieEvent{Upid: "busy"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("Close returned %v, want the 429 retried", err)
+	}
+	if s.Health() != nil {
+		t.Errorf("Health returned %v once every document was indexed", s.Health())
+	}
+}
+
+func TestNewInstallsIndexTemplate(t *testing.T) {
+	c := newCluster(t)
+	c.failPuts = 1
+	cfg := testConfig(c.URL)
+	cfg.Template = "observer"
+	cfg.Username = "observer"
+	cfg.Password = "secret"
+
+	if _, err := New(cfg); err != nil {
+		t.Fatalf("New returned %v, want the template installed after a retry", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.templates) != 2 {
+		t.Fatalf("%d template requests, want 2", len(c.templates))
+	}
+	put := c.templates[1]
+	if put.path != "/_index_template/observer" {
+		t.Errorf("template installed at %s, want /_index_template/observer", put.path)
+	}
+	if put.header.Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", put.header.Get("Content-Type"))
+	}
+	req := &http.Request{Header: put.header}
+	if user, password, ok := req.BasicAuth(); !ok || user != "observer" || password != "secret" {
+		t.Errorf("basic auth = %q, %q, want the configured credentials", user, password)
+	}
+
+	patterns, _ := put.body["index_patterns"].([]any)
+	if len(patterns) != 1 || patterns[0] != "observer-*" {
+		t.Errorf("index_patterns = %v, want [observer-*]", put.body["index_patterns"])
+	}
+	template, _ := put.body["template"].(map[string]any)
+	mappings, _ := template["mappings"].(map[string]any)
+	properties, _ := mappings["properties"].(map[string]any)
+	want := map[string]string{
+		timestampField:        "date",
+		"kubernetesNamespace": "keyword",
+		"latency":             "long",
+		"isServerSideTracing": "boolean",
+	}
+	for field, typ := range want {
+		property, _ := properties[field].(map[string]any)
+		if property["type"] != typ {
+			t.Errorf("%s is mapped as %v, want %s", field, property["type"], typ)
+		}
+	}
+	if _, ok := properties["apiKey"]; ok {
+		t.Error("apiKey is mapped, want it left out")
+	}
+	httpField, _ := properties["http"].(map[string]any)
+	httpProperties, _ := httpField["properties"].(map[string]any)
+	if body, _ := httpProperties["reqBody"].(map[string]any); body["type"] != "text" {
+		t.Errorf("http.reqBody is mapped as %v, want text", body["type"])
+	}
+}
+
+func TestEncodeAddsTimestamp(t *testing.T) {
+	s := &Sink{cfg: config.OpenSearchSinkConfig{Index: "observer"}}
+	doc, err := s.encode(&pb.PixieEvent{Time: "2024-03-01T12:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var source map[string]any
+	if err := json.Unmarshal(doc.source, &source); err != nil {
+		t.Fatalf("document %s is not a JSON object: %v", doc.source, err)
+	}
+	if source[timestampField] != "2024-03-01T12:00:00Z" {
+		t.Errorf("%s = %v, want the event time", timestampField, source[timestampField])
+	}
+}
@@ -0,0 +1,25 @@
+package otlp
+
+import (
+	"strconv"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// attributes builds OTLP attributes, leaving out empty values.
+type attributes []*commonpb.KeyValue
+
+func (a *attributes) str(key string, value string) {
+	if value == "" {
+		return
+	}
+	*a = append(*a, &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}})
+}
+
+func (a *attributes) int(key string, value int64) {
+	*a = append(*a, &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}}})
+}
+
+func itoa(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
@@ -0,0 +1,182 @@
+// Package otlp exports events to an OpenTelemetry collector over OTLP/gRPC
+// or OTLP/HTTP with binary protobuf encoding.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+	"orbservability/observer/pkg/sink/retry"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// client sends export requests to a collector using either transport.
+type client struct {
+	cfg     config.OTLPSinkConfig
+	headers map[string]string
+	conn    *grpc.ClientConn // grpc only
+	http    *http.Client     // http only
+}
+
+func newClient(cfg config.OTLPSinkConfig) (*client, error) {
+	c := &client{cfg: cfg, headers: map[string]string{}}
+	for name, value := range cfg.Headers {
+		c.headers[name] = value.Value()
+	}
+
+	switch cfg.Protocol {
+	case config.ProtocolGRPC:
+		creds := insecure.NewCredentials()
+		if cfg.TLS.Enabled {
+			tlsConfig, err := cfg.TLS.ClientConfig(cfg.Endpoint)
+			if err != nil {
+				return nil, err
+			}
+			creds = credentials.NewTLS(tlsConfig)
+		}
+		conn, err := grpc.Dial(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	case config.ProtocolHTTP:
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if cfg.TLS.Enabled {
+			tlsConfig, err := cfg.TLS.HTTPClientConfig(cfg.Endpoint)
+			if err != nil {
+				return nil, err
+			}
+			transport.TLSClientConfig = tlsConfig
+		}
+		c.http = &http.Client{Transport: transport}
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q", cfg.Protocol)
+	}
+	return c, nil
+}
+
+// signal is one of the OTLP signals, exported on its own gRPC service and HTTP path.
+type signal struct {
+	items    string // What the signal calls its items in logs, e.g. "spans"
+	path     string // Appended to the endpoint for http, e.g. "/v1/traces"
+	export   func(ctx context.Context, conn *grpc.ClientConn, req proto.Message) (proto.Message, error)
+	request  func(events []*pb.PixieEvent) proto.Message
+	response func() proto.Message                     // An empty response, decoded into for http
+	rejected func(resp proto.Message) (int64, string) // Items rejected by a partial success, and why
+}
+
+// export sends req, retrying with backoff while the collector responds with
+// an error it may recover from. A delay asked for by the collector, through
+// Retry-After or a gRPC RetryInfo, is waited instead of the backoff delay.
+func (c *client) export(ctx context.Context, sig signal, req proto.Message) (proto.Message, error) {
+	var resp proto.Message
+	err := retry.Do(ctx, c.cfg.Retry, func(ctx context.Context) error {
+		var err error
+		resp, err = c.exportOnce(ctx, sig, req)
+		return err
+	}, retryDelay)
+	return resp, err
+}
+
+// exportOnce sends req once within the configured timeout.
+func (c *client) exportOnce(ctx context.Context, sig signal, req proto.Message) (proto.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout.Duration())
+	defer cancel()
+
+	if c.conn != nil {
+		if len(c.headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(c.headers))
+		}
+		return sig.export(ctx, c.conn, req)
+	}
+
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.cfg.Endpoint, "/")+sig.path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	for name, value := range c.headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	httpResp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if err := retry.CheckResponse(httpResp); err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(io.LimitReader(httpResp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	resp := sig.response()
+	if err := proto.Unmarshal(respBody, resp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return resp, nil
+}
+
+func (c *client) close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+// retryDelay reports whether an export that failed with err may succeed
+// later, following the OTLP specification, and how long the collector asked
+// to wait before retrying, 0 if it did not say.
+func retryDelay(err error) (time.Duration, bool) {
+	var statusErr *retry.StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.Code {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return statusErr.RetryAfter, true
+		default:
+			return 0, false
+		}
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return 0, true // A network error from the http transport
+	}
+	var delay time.Duration
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			delay = info.GetRetryDelay().AsDuration()
+		}
+	}
+	switch s.Code() {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+		return delay, true
+	case codes.ResourceExhausted:
+		// Only retried when the collector says when it can take more
+		return delay, delay > 0
+	default:
+		return 0, false
+	}
+}
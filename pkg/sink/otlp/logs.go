@@ -0,0 +1,134 @@
+package otlp
+
+import (
+	"context"
+	"time"
+
+	"orbservability/observer/pkg/event"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var logsSignal = signal{
+	items: "log records",
+	path:  "/v1/logs",
+	export: func(ctx context.Context, conn *grpc.ClientConn, req proto.Message) (proto.Message, error) {
+		return collogspb.NewLogsServiceClient(conn).Export(ctx, req.(*collogspb.ExportLogsServiceRequest))
+	},
+	request: func(events []*pb.PixieEvent) proto.Message {
+		return logsRequest(events)
+	},
+	response: func() proto.Message {
+		return &collogspb.ExportLogsServiceResponse{}
+	},
+	rejected: func(resp proto.Message) (int64, string) {
+		partial := resp.(*collogspb.ExportLogsServiceResponse).GetPartialSuccess()
+		return partial.GetRejectedLogRecords(), partial.GetErrorMessage()
+	},
+}
+
+// logsRequest converts events into log records, grouped by resource.
+func logsRequest(events []*pb.PixieEvent) *collogspb.ExportLogsServiceRequest {
+	req := &collogspb.ExportLogsServiceRequest{}
+	scopes := map[resource]*logspb.ScopeLogs{}
+	for _, e := range events {
+		r := resourceOf(e)
+		scopeLogs, found := scopes[r]
+		if !found {
+			scopeLogs = &logspb.ScopeLogs{Scope: scope}
+			scopes[r] = scopeLogs
+			req.ResourceLogs = append(req.ResourceLogs, &logspb.ResourceLogs{
+				Resource:  r.proto(),
+				ScopeLogs: []*logspb.ScopeLogs{scopeLogs},
+			})
+		}
+		scopeLogs.LogRecords = append(scopeLogs.LogRecords, logRecord(e))
+	}
+	return req
+}
+
+// logRecord converts an event into a log record with the protocol data as its body.
+func logRecord(e *pb.PixieEvent) *logspb.LogRecord {
+	record := &logspb.LogRecord{
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		Body:                 body(e),
+	}
+	if at, ok := event.Time(e); ok {
+		record.TimeUnixNano = uint64(at.UnixNano())
+	}
+	record.SeverityNumber, record.SeverityText = severity(e)
+
+	a := attributes(eventAttributes(e))
+	a.int("pixie.latency_ns", e.GetLatency())
+	record.Attributes = a
+	return record
+}
+
+// severity is ERROR for HTTP 5xx and MySQL errors, WARN for HTTP 4xx and
+// INFO otherwise.
+func severity(e *pb.PixieEvent) (logspb.SeverityNumber, string) {
+	switch p := e.GetProtocolData().(type) {
+	case *pb.PixieEvent_Http:
+		switch status := p.Http.GetRespStatus(); {
+		case status >= 500:
+			return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, "ERROR"
+		case status >= 400:
+			return logspb.SeverityNumber_SEVERITY_NUMBER_WARN, "WARN"
+		}
+	case *pb.PixieEvent_Mysql:
+		if p.Mysql.GetRespStatus() == event.MySQLError {
+			return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, "ERROR"
+		}
+	}
+	return logspb.SeverityNumber_SEVERITY_NUMBER_INFO, "INFO"
+}
+
+// body returns the protocol data of the event as a map keyed by field name,
+// leaving out unset fields, or nil when the event carries no protocol data.
+func body(e *pb.PixieEvent) *commonpb.AnyValue {
+	m := e.ProtoReflect()
+	field := m.WhichOneof(m.Descriptor().Oneofs().ByName("protocol_data"))
+	if field == nil {
+		return nil
+	}
+	return anyValue(field, m.Get(field))
+}
+
+// anyValue converts a singular protobuf field value into an OTLP value.
+func anyValue(field protoreflect.FieldDescriptor, v protoreflect.Value) *commonpb.AnyValue {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.String()}}
+	case protoreflect.BoolKind:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.Bool()}}
+	case protoreflect.Int32Kind, protoreflect.Int64Kind, protoreflect.Sint32Kind, protoreflect.Sint64Kind,
+		protoreflect.Sfixed32Kind, protoreflect.Sfixed64Kind:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.Int()}}
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind, protoreflect.Fixed32Kind, protoreflect.Fixed64Kind:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v.Uint())}}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.Float()}}
+	case protoreflect.BytesKind:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: v.Bytes()}}
+	case protoreflect.EnumKind:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v.Enum())}}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		var values []*commonpb.KeyValue
+		v.Message().Range(func(field protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			if field.IsList() || field.IsMap() {
+				return true // Not used by the protocol messages
+			}
+			values = append(values, &commonpb.KeyValue{Key: string(field.Name()), Value: anyValue(field, v)})
+			return true
+		})
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: values}}}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,45 @@
+package otlp
+
+import (
+	"strings"
+
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// scope identifies the observer as the instrumentation that produced the telemetry.
+var scope = &commonpb.InstrumentationScope{Name: "orbservability/observer"}
+
+// resource is the workload an event was observed in.
+type resource struct {
+	source    string
+	namespace string
+	service   string
+}
+
+func resourceOf(e *pb.PixieEvent) resource {
+	return resource{
+		source:    e.GetSource(),
+		namespace: e.GetKubernetesNamespace(),
+		service:   e.GetKubernetesService(),
+	}
+}
+
+func (r resource) proto() *resourcepb.Resource {
+	// Pixie names services "namespace/service"
+	_, service, found := strings.Cut(r.service, "/")
+	if !found {
+		service = r.service
+	}
+	if service == "" {
+		service = "unknown_service"
+	}
+
+	var a attributes
+	a.str("service.name", service)
+	a.str("k8s.namespace.name", r.namespace)
+	a.str("pixie.source", r.source)
+	return &resourcepb.Resource{Attributes: a}
+}
@@ -0,0 +1,92 @@
+package otlp
+
+import (
+	"context"
+	"sync"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Sink exports events as one OTLP signal. Events are batched until the
+// batch is full or the sink is flushed. Sink is not safe for concurrent use.
+type Sink struct {
+	client    *client
+	signal    signal
+	batchSize int
+	batch     []*pb.PixieEvent
+
+	mu  sync.Mutex
+	err error // Last export error, reported by Health
+}
+
+// NewTraces connects to the collector described by cfg and exports every event as a span.
+func NewTraces(cfg config.OTLPSinkConfig) (*Sink, error) {
+	return newSink(cfg, tracesSignal)
+}
+
+// NewLogs connects to the collector described by cfg and exports every event as a log record.
+func NewLogs(cfg config.OTLPSinkConfig) (*Sink, error) {
+	return newSink(cfg, logsSignal)
+}
+
+func newSink(cfg config.OTLPSinkConfig, sig signal) (*Sink, error) {
+	c, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{client: c, signal: sig, batchSize: cfg.BatchSize}, nil
+}
+
+func (s *Sink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	s.batch = append(s.batch, e)
+	if len(s.batch) < s.batchSize {
+		return nil
+	}
+	return s.Flush(ctx)
+}
+
+// Flush exports the batch. A batch that still fails after its retries is
+// dropped, so that one rejected batch does not block the sink.
+func (s *Sink) Flush(ctx context.Context) error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	req := s.signal.request(s.batch)
+	s.batch = s.batch[:0]
+
+	resp, err := s.client.export(ctx, s.signal, req)
+	if err == nil {
+		if rejected, reason := s.signal.rejected(resp); rejected > 0 {
+			log.Warn().Int64("rejected", rejected).Str("reason", reason).Msgf("Collector rejected %s", s.signal.items)
+		}
+	}
+
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+	return err
+}
+
+// Probe exports a request without any item, which the collector accepts
+// without exporting anything.
+func (s *Sink) Probe(ctx context.Context) error {
+	_, err := s.client.exportOnce(ctx, s.signal, s.signal.request(nil))
+	return err
+}
+
+func (s *Sink) Close(ctx context.Context) error {
+	err := s.Flush(ctx)
+	if closeErr := s.client.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *Sink) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
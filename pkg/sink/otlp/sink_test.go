@@ -0,0 +1,345 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+	"orbservability/observer/pkg/sink/retry"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var testRetry = config.RetryConfig{
+	MaxAttempts: 3,
+	Backoff:     config.BackoffConfig{Base: config.Duration(time.Millisecond), Max: config.Duration(time.Millisecond)},
+}
+
+// httpEvent is a request served by checkout, answered 503 after 25ms.
+func httpEvent() *pb.PixieEvent {
+	return &pb.PixieEvent{
+		Time:                    "2024-01-01T12:00:00Z",
+		Latency:                 int64(25 * time.Millisecond),
+		KubernetesNamespace:     "shop",
+		KubernetesService:       "shop/checkout",
+		KubernetesRemoteService: "shop/frontend",
+		RemotePort:              51234,
+		IsServerSideTracing:     true,
+		Source:                  "prod",
+		ProtocolData: &pb.PixieEvent_Http{Http: &pb.HypertextTransferProtocol{
+			MajorVersion: 1,
+			MinorVersion: 1,
+			ReqMethod:    "POST",
+			ReqPath:      "/cart",
+			RespStatus:   503,
+		}},
+	}
+}
+
+// captureLogs collects what is logged until the test ends.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = logger })
+	return &buf
+}
+
+func attributeMap(kvs []*commonpb.KeyValue) map[string]any {
+	m := map[string]any{}
+	for _, kv := range kvs {
+		switch v := kv.GetValue().GetValue().(type) {
+		case *commonpb.AnyValue_StringValue:
+			m[kv.GetKey()] = v.StringValue
+		case *commonpb.AnyValue_IntValue:
+			m[kv.GetKey()] = v.IntValue
+		}
+	}
+	return m
+}
+
+func checkAttributes(t *testing.T, what string, kvs []*commonpb.KeyValue, want map[string]any) {
+	t.Helper()
+	got := attributeMap(kvs)
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s attribute %s = %v, want %v", what, key, got[key], value)
+		}
+	}
+}
+
+// traceCollector is an in-memory OTLP/gRPC trace collector. It fails the
+// first exports with failures, then accepts the rest, rejecting spans with
+// partial.
+type traceCollector struct {
+	coltracepb.UnimplementedTraceServiceServer
+	failures []error
+	partial  *coltracepb.ExportTracePartialSuccess
+
+	mu       sync.Mutex
+	calls    []time.Time
+	requests []*coltracepb.ExportTraceServiceRequest
+	metadata metadata.MD
+}
+
+func (c *traceCollector) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, time.Now())
+	if len(c.calls) <= len(c.failures) {
+		return nil, c.failures[len(c.calls)-1]
+	}
+	c.requests = append(c.requests, req)
+	c.metadata, _ = metadata.FromIncomingContext(ctx)
+	return &coltracepb.ExportTraceServiceResponse{PartialSuccess: c.partial}, nil
+}
+
+// newGRPCSink returns a sink exporting to collector over an in-memory connection.
+func newGRPCSink(t *testing.T, collector coltracepb.TraceServiceServer, cfg config.OTLPSinkConfig) *Sink {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(server, collector)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	c := &client{cfg: cfg, headers: map[string]string{}, conn: conn}
+	for name, value := range cfg.Headers {
+		c.headers[name] = value.Value()
+	}
+	return &Sink{client: c, signal: tracesSignal, batchSize: cfg.BatchSize}
+}
+
+func unavailable(t *testing.T, retryDelay time.Duration) error {
+	t.Helper()
+	s, err := status.New(codes.Unavailable, "collector restarting").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryDelay)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.Err()
+}
+
+func TestTracesOverGRPC(t *testing.T) {
+	logs := captureLogs(t)
+	collector := &traceCollector{
+		failures: []error{unavailable(t, 50*time.Millisecond)},
+		partial:  &coltracepb.ExportTracePartialSuccess{RejectedSpans: 1, ErrorMessage: "span too large"},
+	}
+	s := newGRPCSink(t, collector, config.OTLPSinkConfig{
+		Headers:   map[string]config.Secret{"authorization": "Bearer token"},
+		Timeout:   config.Duration(time.Second),
+		BatchSize: 10,
+		Retry:     testRetry,
+	})
+
+	if err := s.Send(context.Background(), httpEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("Close returned %v, want the export retried after the delay in RetryInfo", err)
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if len(collector.calls) != 2 {
+		t.Fatalf("%d export calls, want 2", len(collector.calls))
+	}
+	if waited := collector.calls[1].Sub(collector.calls[0]); waited < 50*time.Millisecond {
+		t.Errorf("retried after %s, want at least the 50ms asked for by RetryInfo", waited)
+	}
+	if got := collector.metadata.Get("authorization"); len(got) != 1 || got[0] != "Bearer token" {
+		t.Errorf("authorization metadata = %q, want the configured header", got)
+	}
+
+	resourceSpans := collector.requests[0].GetResourceSpans()
+	if len(resourceSpans) != 1 || len(resourceSpans[0].GetScopeSpans()) != 1 || len(resourceSpans[0].GetScopeSpans()[0].GetSpans()) != 1 {
+		t.Fatalf("request = %v, want a single span", collector.requests[0])
+	}
+	checkAttributes(t, "resource", resourceSpans[0].GetResource().GetAttributes(), map[string]any{
+		"service.name":       "checkout",
+		"k8s.namespace.name": "shop",
+		"pixie.source":       "prod",
+	})
+
+	span := resourceSpans[0].GetScopeSpans()[0].GetSpans()[0]
+	end := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if got := time.Unix(0, int64(span.GetEndTimeUnixNano())); !got.Equal(end) {
+		t.Errorf("end = %s, want the event time %s", got, end)
+	}
+	if got, want := time.Unix(0, int64(span.GetStartTimeUnixNano())), end.Add(-25*time.Millisecond); !got.Equal(want) {
+		t.Errorf("start = %s, want the event time less the latency, %s", got, want)
+	}
+	if span.GetName() != "POST" || span.GetKind().String() != "SPAN_KIND_SERVER" || span.GetStatus().GetCode().String() != "STATUS_CODE_ERROR" {
+		t.Errorf("span name %q, kind %s, status %s, want POST, SPAN_KIND_SERVER and STATUS_CODE_ERROR", span.GetName(), span.GetKind(), span.GetStatus().GetCode())
+	}
+	if len(span.GetTraceId()) != 16 || len(span.GetSpanId()) != 8 {
+		t.Errorf("trace ID of %d bytes and span ID of %d bytes, want 16 and 8", len(span.GetTraceId()), len(span.GetSpanId()))
+	}
+	checkAttributes(t, "span", span.GetAttributes(), map[string]any{
+		"client.address":            "shop/frontend",
+		"client.port":               int64(51234),
+		"network.protocol.name":     "http",
+		"network.protocol.version":  "1.1",
+		"http.request.method":       "POST",
+		"url.path":                  "/cart",
+		"http.response.status_code": int64(503),
+	})
+
+	if !strings.Contains(logs.String(), `"rejected":1`) || !strings.Contains(logs.String(), "span too large") {
+		t.Errorf("logs = %s, want the partial success logged", logs)
+	}
+}
+
+func TestTracesOverGRPCGivesUpOnPermanentError(t *testing.T) {
+	collector := &traceCollector{failures: []error{status.Error(codes.InvalidArgument, "bad span")}}
+	s := newGRPCSink(t, collector, config.OTLPSinkConfig{Timeout: config.Duration(time.Second), BatchSize: 1, Retry: testRetry})
+
+	err := s.Send(context.Background(), httpEvent())
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Send returned %v, want InvalidArgument", err)
+	}
+	if s.Health() == nil {
+		t.Error("Health returned nil after a failed export")
+	}
+	if len(collector.calls) != 1 {
+		t.Errorf("%d export calls, want 1", len(collector.calls))
+	}
+}
+
+func TestLogsOverHTTP(t *testing.T) {
+	logs := captureLogs(t)
+	var (
+		mu       sync.Mutex
+		calls    int
+		requests []*collogspb.ExportLogsServiceRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/x-protobuf" || r.Header.Get("X-Tenant") != "shop" {
+			t.Errorf("request to %s with Content-Type %q and X-Tenant %q", r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("X-Tenant"))
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		req := &collogspb.ExportLogsServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, req)
+		resp, _ := proto.Marshal(&collogspb.ExportLogsServiceResponse{PartialSuccess: &collogspb.ExportLogsPartialSuccess{RejectedLogRecords: 2, ErrorMessage: "quota"}})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(resp)
+	}))
+	defer server.Close()
+
+	s, err := NewLogs(config.OTLPSinkConfig{
+		Protocol:  config.ProtocolHTTP,
+		Endpoint:  server.URL + "/",
+		Headers:   map[string]config.Secret{"X-Tenant": "shop"},
+		Timeout:   config.Duration(time.Second),
+		BatchSize: 10,
+		Retry:     testRetry,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(context.Background(), httpEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("Close returned %v, want the export retried after the 429", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 2 || len(requests) != 1 {
+		t.Fatalf("%d calls and %d accepted requests, want 2 and 1", calls, len(requests))
+	}
+	record := requests[0].GetResourceLogs()[0].GetScopeLogs()[0].GetLogRecords()[0]
+	if got, want := time.Unix(0, int64(record.GetTimeUnixNano())), time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("time = %s, want %s", got, want)
+	}
+	if record.GetSeverityNumber() != logspb.SeverityNumber_SEVERITY_NUMBER_ERROR || record.GetSeverityText() != "ERROR" {
+		t.Errorf("severity = %s %q, want ERROR for a 503", record.GetSeverityNumber(), record.GetSeverityText())
+	}
+	checkAttributes(t, "log record", record.GetAttributes(), map[string]any{
+		"http.request.method": "POST",
+		"pixie.latency_ns":    int64(25 * time.Millisecond),
+	})
+	if !strings.Contains(logs.String(), `"rejected":2`) || !strings.Contains(logs.String(), "quota") {
+		t.Errorf("logs = %s, want the partial success logged", logs)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	withRetryInfo := func(code codes.Code, delay time.Duration) error {
+		s, err := status.New(code, "").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Err()
+	}
+
+	tests := []struct {
+		name  string
+		err   error
+		delay time.Duration
+		retry bool
+	}{
+		{"unavailable", status.Error(codes.Unavailable, ""), 0, true},
+		{"unavailable with retry info", withRetryInfo(codes.Unavailable, 2*time.Second), 2 * time.Second, true},
+		{"resource exhausted", status.Error(codes.ResourceExhausted, ""), 0, false},
+		{"resource exhausted with retry info", withRetryInfo(codes.ResourceExhausted, time.Second), time.Second, true},
+		{"invalid argument", withRetryInfo(codes.InvalidArgument, time.Second), 0, false},
+		{"network error", errors.New("connection refused"), 0, true},
+		{"too many requests", &retry.StatusError{Code: http.StatusTooManyRequests, RetryAfter: 3 * time.Second}, 3 * time.Second, true},
+		{"service unavailable", &retry.StatusError{Code: http.StatusServiceUnavailable}, 0, true},
+		{"internal server error", &retry.StatusError{Code: http.StatusInternalServerError}, 0, false},
+		{"bad request", &retry.StatusError{Code: http.StatusBadRequest}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retry := retryDelay(tt.err)
+			if delay != tt.delay || retry != tt.retry {
+				t.Errorf("retryDelay = %s, %t, want %s, %t", delay, retry, tt.delay, tt.retry)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package otlp
+
+import (
+	"context"
+	"crypto/rand"
+	"time"
+
+	"orbservability/observer/pkg/event"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+var tracesSignal = signal{
+	items: "spans",
+	path:  "/v1/traces",
+	export: func(ctx context.Context, conn *grpc.ClientConn, req proto.Message) (proto.Message, error) {
+		return coltracepb.NewTraceServiceClient(conn).Export(ctx, req.(*coltracepb.ExportTraceServiceRequest))
+	},
+	request: func(events []*pb.PixieEvent) proto.Message {
+		return tracesRequest(events)
+	},
+	response: func() proto.Message {
+		return &coltracepb.ExportTraceServiceResponse{}
+	},
+	rejected: func(resp proto.Message) (int64, string) {
+		partial := resp.(*coltracepb.ExportTraceServiceResponse).GetPartialSuccess()
+		return partial.GetRejectedSpans(), partial.GetErrorMessage()
+	},
+}
+
+// tracesRequest converts events into spans, grouped by resource.
+func tracesRequest(events []*pb.PixieEvent) *coltracepb.ExportTraceServiceRequest {
+	req := &coltracepb.ExportTraceServiceRequest{}
+	scopes := map[resource]*tracepb.ScopeSpans{}
+	for _, e := range events {
+		r := resourceOf(e)
+		scopeSpans, found := scopes[r]
+		if !found {
+			scopeSpans = &tracepb.ScopeSpans{Scope: scope}
+			scopes[r] = scopeSpans
+			req.ResourceSpans = append(req.ResourceSpans, &tracepb.ResourceSpans{
+				Resource:   r.proto(),
+				ScopeSpans: []*tracepb.ScopeSpans{scopeSpans},
+			})
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, span(e))
+	}
+	return req
+}
+
+// span converts an event into a span ending at the event's time and lasting its latency.
+func span(e *pb.PixieEvent) *tracepb.Span {
+	end, ok := event.Time(e)
+	if !ok {
+		end = time.Now()
+	}
+	start := end.Add(-time.Duration(e.GetLatency()))
+
+	kind := tracepb.Span_SPAN_KIND_CLIENT
+	if e.GetIsServerSideTracing() {
+		kind = tracepb.Span_SPAN_KIND_SERVER
+	}
+
+	return &tracepb.Span{
+		TraceId:           randomID(16),
+		SpanId:            randomID(8),
+		Name:              spanName(e),
+		Kind:              kind,
+		StartTimeUnixNano: uint64(start.UnixNano()),
+		EndTimeUnixNano:   uint64(end.UnixNano()),
+		Attributes:        eventAttributes(e),
+		Status:            spanStatus(e),
+	}
+}
+
+// spanName follows the semantic conventions: the HTTP method, or the database
+// operation, falling back to the protocol.
+func spanName(e *pb.PixieEvent) string {
+	var name string
+	switch p := e.GetProtocolData().(type) {
+	case *pb.PixieEvent_Http:
+		name = p.Http.GetReqMethod()
+	case *pb.PixieEvent_Pgsql:
+		name = p.Pgsql.GetReqCmd()
+	case *pb.PixieEvent_Redis:
+		name = p.Redis.GetReqCmd()
+	case *pb.PixieEvent_Nats:
+		name = p.Nats.GetCmd()
+	}
+	if name == "" {
+		name = event.Protocol(e)
+	}
+	return name
+}
+
+// eventAttributes describes the connection and the protocol of an event with
+// semantic convention attributes.
+func eventAttributes(e *pb.PixieEvent) []*commonpb.KeyValue {
+	var a attributes
+
+	// The remote end is the server of client spans and the client of server spans
+	peer := "server"
+	if e.GetIsServerSideTracing() {
+		peer = "client"
+	}
+	address := e.GetKubernetesRemoteService()
+	if address == "" {
+		address = e.GetRemoteAddr()
+	}
+	a.str(peer+".address", address)
+	if e.GetRemotePort() != 0 {
+		a.int(peer+".port", int64(e.GetRemotePort()))
+	}
+	a.str("network.protocol.name", event.Protocol(e))
+	a.str("k8s.namespace.name", e.GetKubernetesNamespace())
+	a.str("pixie.upid", e.GetUpid())
+
+	switch p := e.GetProtocolData().(type) {
+	case *pb.PixieEvent_Http:
+		h := p.Http
+		a.str("http.request.method", h.GetReqMethod())
+		a.str("url.path", h.GetReqPath())
+		if h.GetRespStatus() != 0 {
+			a.int("http.response.status_code", int64(h.GetRespStatus()))
+		}
+		if h.GetMajorVersion() != 0 {
+			a.str("network.protocol.version", httpVersion(h))
+		}
+		a.int("http.request.body.size", h.GetReqBodySize())
+		a.int("http.response.body.size", h.GetRespBodySize())
+	case *pb.PixieEvent_Pgsql:
+		a.str("db.system", "postgresql")
+		a.str("db.operation", p.Pgsql.GetReqCmd())
+		a.str("db.statement", p.Pgsql.GetReq())
+	case *pb.PixieEvent_Mysql:
+		a.str("db.system", "mysql")
+		a.str("db.statement", p.Mysql.GetReqBody())
+	case *pb.PixieEvent_Redis:
+		a.str("db.system", "redis")
+		a.str("db.operation", p.Redis.GetReqCmd())
+		a.str("db.statement", event.Request(e))
+	case *pb.PixieEvent_Cql:
+		a.str("db.system", "cassandra")
+		a.str("db.statement", p.Cql.GetReqBody())
+	case *pb.PixieEvent_Kafka:
+		a.str("messaging.system", "kafka")
+		a.str("messaging.client_id", p.Kafka.GetClientId())
+	case *pb.PixieEvent_Nats:
+		a.str("messaging.system", "nats")
+		a.str("messaging.operation", p.Nats.GetCmd())
+	case *pb.PixieEvent_Amqp:
+		a.str("messaging.system", "amqp")
+	}
+	return a
+}
+
+// spanStatus marks server errors of HTTP server spans, and client and server
+// errors of HTTP client spans, as errors.
+func spanStatus(e *pb.PixieEvent) *tracepb.Status {
+	h, ok := e.GetProtocolData().(*pb.PixieEvent_Http)
+	if !ok {
+		return nil
+	}
+	status := h.Http.GetRespStatus()
+	if status >= 500 || status >= 400 && !e.GetIsServerSideTracing() {
+		return &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR}
+	}
+	return nil
+}
+
+func httpVersion(h *pb.HypertextTransferProtocol) string {
+	if h.GetMajorVersion() >= 2 {
+		return itoa(int64(h.GetMajorVersion()))
+	}
+	return itoa(int64(h.GetMajorVersion())) + "." + itoa(int64(h.GetMinorVersion()))
+}
+
+func randomID(n int) []byte {
+	id := make([]byte, n)
+	rand.Read(id)
+	return id
+}
@@ -0,0 +1,273 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	queueDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "observer_queue_dropped_events_total",
+		Help: "Events dropped because a sink queue was full or the sink failed to send them.",
+	}, []string{"sink", "reason"})
+
+	queueLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "observer_queue_length",
+		Help: "Events waiting in a sink queue.",
+	}, []string{"sink"})
+
+	sinkSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "observer_sink_sent_events_total",
+		Help: "Events handed to a sink.",
+	}, []string{"sink"})
+)
+
+var errQueueClosed = errors.New("sink queue closed")
+
+// Queue buffers events in front of a Sink. Only the queue's own goroutine
+// calls the sink, so the sink need not be safe for concurrent use while the
+// Queue is.
+type Queue struct {
+	name          string
+	sink          Sink
+	items         chan item
+	drop          bool // Discard new events when full, rather than block
+	failOnError   bool // Stop on the first error, rather than drop the event
+	drainDeadline time.Duration
+	flushInterval time.Duration
+	errLog        zerolog.Logger
+
+	ctx    context.Context // Passed to the sink, cancelled when Close gives up
+	cancel context.CancelFunc
+
+	stopped  chan struct{} // No longer accepting events
+	stopOnce sync.Once
+	err      error         // Why the queue stopped
+	done     chan struct{} // The queue's goroutine returned
+	closeErr error         // Returned by the sink's Close
+}
+
+// item is a queued event, or a flush request when flushed is set.
+type item struct {
+	event   *pb.PixieEvent
+	flushed chan error
+}
+
+// NewQueue starts a queue in front of sink, configured by cfg.
+func NewQueue(sink Sink, cfg config.SinkConfig) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		name:          cfg.Name,
+		sink:          sink,
+		items:         make(chan item, cfg.Queue.Size),
+		drop:          cfg.Queue.Overflow == config.OverflowDrop,
+		failOnError:   cfg.OnError == config.OnErrorFail,
+		drainDeadline: cfg.Queue.DrainDeadline.Duration(),
+		flushInterval: cfg.Queue.FlushInterval.Duration(),
+		errLog:        log.With().Str("sink", cfg.Name).Logger().Sample(&zerolog.BurstSampler{Burst: 1, Period: 10 * time.Second}),
+		ctx:           ctx,
+		cancel:        cancel,
+		stopped:       make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Send enqueues e. When the queue is full it blocks, or drops e if the queue
+// was configured to drop. It returns the reason the queue stopped once it has.
+func (q *Queue) Send(ctx context.Context, e *pb.PixieEvent) error {
+	select {
+	case <-q.stopped:
+		return q.err
+	default:
+	}
+
+	if q.drop {
+		select {
+		case q.items <- item{event: e}:
+			queueLength.WithLabelValues(q.name).Inc()
+		case <-q.stopped:
+			return q.err
+		default:
+			queueDropped.WithLabelValues(q.name, "overflow").Inc()
+		}
+		return nil
+	}
+
+	select {
+	case q.items <- item{event: e}:
+		queueLength.WithLabelValues(q.name).Inc()
+		return nil
+	case <-q.stopped:
+		return q.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush waits until the events queued so far have been sent and the sink flushed.
+func (q *Queue) Flush(ctx context.Context) error {
+	flushed := make(chan error, 1)
+	select {
+	case <-q.stopped:
+		return q.err
+	default:
+	}
+	select {
+	case q.items <- item{flushed: flushed}:
+	case <-q.stopped:
+		return q.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-flushed:
+		return err
+	case <-q.done:
+		return q.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events and sends those still queued until the queue
+// is empty or the drain deadline passes, then closes the sink. If ctx is done
+// first, the sink's pending calls are cancelled.
+func (q *Queue) Close(ctx context.Context) error {
+	q.stop(errQueueClosed)
+	select {
+	case <-q.done:
+	case <-ctx.Done():
+		q.cancel()
+		<-q.done
+	}
+	return q.closeErr
+}
+
+// Health reports why the queue stopped, or else the health of the sink.
+func (q *Queue) Health() error {
+	select {
+	case <-q.stopped:
+		if q.err != errQueueClosed {
+			return q.err
+		}
+	default:
+	}
+	return q.sink.Health()
+}
+
+// Done is closed once the queue has stopped, see Err.
+func (q *Queue) Done() <-chan struct{} {
+	return q.done
+}
+
+// Err returns why the queue stopped: errQueueClosed after Close, or the sink
+// error that stopped a queue configured to fail.
+func (q *Queue) Err() error {
+	<-q.done
+	return q.err
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	defer q.cancel()
+
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stopped:
+			q.drain()
+			q.closeSink()
+			return
+		case it := <-q.items:
+			if err := q.handle(it); err != nil {
+				q.stop(err)
+				q.closeSink()
+				return
+			}
+		case <-ticker.C:
+			if err := q.check(q.sink.Flush(q.ctx)); err != nil {
+				q.stop(err)
+				q.closeSink()
+				return
+			}
+		}
+	}
+}
+
+// handle sends or flushes it, returning an error only if it stops the queue.
+func (q *Queue) handle(it item) error {
+	if it.flushed != nil {
+		err := q.sink.Flush(q.ctx)
+		it.flushed <- err
+		return q.check(err)
+	}
+
+	queueLength.WithLabelValues(q.name).Dec()
+	err := q.sink.Send(q.ctx, it.event)
+	if err == nil {
+		sinkSent.WithLabelValues(q.name).Inc()
+		return nil
+	}
+	queueDropped.WithLabelValues(q.name, "error").Inc()
+	return q.check(err)
+}
+
+// check applies the queue's error handling to err, returning it only if it
+// stops the queue.
+func (q *Queue) check(err error) error {
+	if err == nil {
+		return nil
+	}
+	if q.failOnError {
+		return err
+	}
+	q.errLog.Error().Err(err).Msg("Error sending events, dropping them")
+	return nil
+}
+
+func (q *Queue) drain() {
+	deadline := time.After(q.drainDeadline)
+	for {
+		select {
+		case <-deadline:
+			log.Warn().Str("sink", q.name).Int("remaining", len(q.items)).Msg("Drain deadline passed, dropping queued events")
+			return
+		case it := <-q.items:
+			if err := q.handle(it); err != nil {
+				log.Error().Err(err).Str("sink", q.name).Msg("Error draining queued events")
+				return
+			}
+		default:
+			return // Empty
+		}
+	}
+}
+
+func (q *Queue) closeSink() {
+	q.closeErr = q.sink.Close(q.ctx)
+	if q.closeErr != nil {
+		log.Error().Err(q.closeErr).Str("sink", q.name).Msg("Error closing sink")
+	}
+}
+
+func (q *Queue) stop(err error) {
+	q.stopOnce.Do(func() {
+		q.err = err
+		close(q.stopped)
+	})
+}
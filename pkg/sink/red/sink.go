@@ -0,0 +1,176 @@
+// Package red derives request rate, error and duration (RED) metrics from
+// events and serves them on the observer's metrics endpoint.
+package red
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/event"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// overflow replaces label values beyond the allowed ones.
+const overflow = "_other"
+
+var labels = []string{"namespace", "service", "remote_service", "protocol", "method", "status_class"}
+
+// methods are the HTTP methods kept as method label values.
+var methods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "DELETE": true,
+	"CONNECT": true, "OPTIONS": true, "TRACE": true, "PATCH": true,
+}
+
+var (
+	mu         sync.Mutex
+	registered bool    // newest is registered with the default Prometheus registry
+	opened     []*Sink // Not closed yet, oldest first
+)
+
+// newest collects the metrics of the newest open Sink only, so that a Sink can
+// be replaced by one with a different configuration while both are open.
+// It describes no metrics, as their buckets depend on the Sink.
+type newest struct{}
+
+func (newest) Describe(ch chan<- *prometheus.Desc) {}
+
+func (newest) Collect(ch chan<- prometheus.Metric) {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(opened) == 0 {
+		return
+	}
+	for _, c := range opened[len(opened)-1].collectors() {
+		c.Collect(ch)
+	}
+}
+
+// Sink records every event as a request. Sink is not safe for concurrent use.
+type Sink struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	namespaces     *limiter
+	services       *limiter
+	remoteServices *limiter
+}
+
+// New returns a Sink whose metrics are served in place of those of the Sinks
+// opened before it, until it is closed.
+func New(cfg config.MetricsSinkConfig) (*Sink, error) {
+	s := &Sink{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "observer_requests_total",
+			Help: "Requests observed by Pixie.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "observer_request_errors_total",
+			Help: "Requests observed by Pixie that failed with an HTTP 5xx response or a MySQL error.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "observer_request_duration_seconds",
+			Help:    "Latency of requests observed by Pixie.",
+			Buckets: cfg.Buckets,
+		}, labels),
+		namespaces:     newLimiter(cfg.Namespaces, cfg.MaxValues),
+		services:       newLimiter(cfg.Services, cfg.MaxValues),
+		remoteServices: newLimiter(cfg.RemoteServices, cfg.MaxValues),
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !registered {
+		if err := prometheus.Register(newest{}); err != nil {
+			return nil, err
+		}
+		registered = true
+	}
+	opened = append(opened, s)
+	return s, nil
+}
+
+func (s *Sink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	var method string
+	if h, ok := e.GetProtocolData().(*pb.PixieEvent_Http); ok {
+		method = h.Http.GetReqMethod()
+		if !methods[method] {
+			method = overflow
+		}
+	}
+	values := []string{
+		s.namespaces.value(e.GetKubernetesNamespace()),
+		s.services.value(e.GetKubernetesService()),
+		s.remoteServices.value(e.GetKubernetesRemoteService()),
+		event.Protocol(e),
+		method,
+		event.StatusClass(e),
+	}
+
+	s.requests.WithLabelValues(values...).Inc()
+	if event.Failed(e) {
+		s.errors.WithLabelValues(values...).Inc()
+	}
+	s.duration.WithLabelValues(values...).Observe(time.Duration(e.GetLatency()).Seconds())
+	return nil
+}
+
+// Flush does nothing, metrics are updated as events are sent.
+func (s *Sink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close stops serving the metrics, falling back to those of the newest Sink
+// still open.
+func (s *Sink) Close(ctx context.Context) error {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, o := range opened {
+		if o == s {
+			opened = append(opened[:i], opened[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *Sink) Health() error {
+	return nil
+}
+
+func (s *Sink) collectors() []prometheus.Collector {
+	return []prometheus.Collector{s.requests, s.errors, s.duration}
+}
+
+// limiter bounds the values of a label to an allow-list, or to the first
+// max values seen when there is none.
+type limiter struct {
+	allowed map[string]bool
+	fixed   bool // allowed is an allow-list
+	max     int
+}
+
+func newLimiter(allowList []string, max int) *limiter {
+	l := &limiter{allowed: map[string]bool{}, fixed: len(allowList) > 0, max: max}
+	for _, value := range allowList {
+		l.allowed[value] = true
+	}
+	return l
+}
+
+// value returns v if it is allowed, and the overflow value otherwise.
+// An empty value, such as the remote service of an external peer, is always allowed.
+func (l *limiter) value(v string) string {
+	if v == "" || l.allowed[v] {
+		return v
+	}
+	if l.fixed || len(l.allowed) >= l.max {
+		return overflow
+	}
+	l.allowed[v] = true
+	return v
+}
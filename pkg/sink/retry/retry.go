@@ -0,0 +1,108 @@
+// Package retry retries the requests of sinks whose remote end may accept
+// them later, with exponential backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"orbservability/observer/pkg/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Do calls attempt until it succeeds, fails with an error that retryable
+// reports as permanent, has been called cfg.MaxAttempts times or ctx is done,
+// and returns the last error. A delay returned by retryable, such as one
+// asked for with Retry-After, is waited instead of the backoff delay.
+func Do(ctx context.Context, cfg config.RetryConfig, attempt func(ctx context.Context) error, retryable func(err error) (time.Duration, bool)) error {
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		if err == nil || n >= cfg.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		delay, ok := retryable(err)
+		if !ok {
+			return err
+		}
+		if delay == 0 {
+			delay = cfg.Backoff.Delay(n)
+		}
+
+		log.Debug().Err(err).Int("attempt", n).Dur("delay", delay).Msg("Retrying request")
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// After parses a Retry-After header given in seconds or as an HTTP date,
+// returning 0 when it is absent or already passed.
+func After(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if delay := time.Until(at); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// StatusError is a non 2xx response to an HTTP request.
+type StatusError struct {
+	Code       int
+	RetryAfter time.Duration // From the Retry-After header, 0 when absent
+	Message    string        // The start of a text or JSON response body
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("responded %d %s", e.Code, http.StatusText(e.Code))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// CheckResponse returns a *StatusError for a non 2xx response, reading
+// the body into its message.
+func CheckResponse(resp *http.Response) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	err := &StatusError{Code: resp.StatusCode, RetryAfter: After(resp.Header.Get("Retry-After"))}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err.Message = strings.TrimSpace(string(body))
+	}
+	return err
+}
+
+// HTTP retries network errors, and 429 and 5xx responses after the delay
+// they ask for.
+func HTTP(err error) (time.Duration, bool) {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return 0, true
+	}
+	if statusErr.Code == http.StatusTooManyRequests || statusErr.Code >= 500 {
+		return statusErr.RetryAfter, true
+	}
+	return 0, false
+}
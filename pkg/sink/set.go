@@ -0,0 +1,266 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/eventgateway"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+	"orbservability/observer/pkg/sink/archive"
+	"orbservability/observer/pkg/sink/file"
+	"orbservability/observer/pkg/sink/kafka"
+	"orbservability/observer/pkg/sink/loki"
+	"orbservability/observer/pkg/sink/opensearch"
+	"orbservability/observer/pkg/sink/otlp"
+	"orbservability/observer/pkg/sink/red"
+	"orbservability/observer/pkg/sink/stdout"
+	"orbservability/observer/pkg/sink/subscription"
+	"orbservability/observer/pkg/sink/webhook"
+)
+
+// Set sends every event to each of the configured sinks through their queues.
+// Its sinks can be replaced while events are sent, see Prepare.
+type Set struct {
+	mu      sync.RWMutex
+	members []member
+	failed  chan error
+}
+
+// member is a sink's queue along with what the sink was opened with.
+type member struct {
+	cfg     config.SinkConfig
+	gateway *eventgateway.ServiceClient // Used by gateway sinks only
+	queue   *Queue
+}
+
+// Open creates the sinks in cfg.Sinks, each behind its own queue.
+// gateway is the event gateway client, used by gateway sinks.
+func Open(cfg *config.Config, gateway *eventgateway.ServiceClient) (*Set, error) {
+	set := &Set{failed: make(chan error, 1)}
+	for _, sc := range cfg.Sinks {
+		m, err := set.open(cfg, sc, gateway)
+		if err != nil {
+			set.Close(context.Background())
+			return nil, err
+		}
+		set.members = append(set.members, m)
+	}
+	return set, nil
+}
+
+// open creates the sink configured by sc behind a queue whose failure is
+// reported by Failed.
+func (s *Set) open(cfg *config.Config, sc config.SinkConfig, gateway *eventgateway.ServiceClient) (member, error) {
+	sink, err := open(cfg, sc, gateway)
+	if err != nil {
+		return member{}, fmt.Errorf("sink %s: %w", sc.Name, err)
+	}
+	q := NewQueue(sink, sc)
+	go func() {
+		if err := q.Err(); err != errQueueClosed {
+			select {
+			case s.failed <- fmt.Errorf("sink %s: %w", sc.Name, err):
+			default: // A failure is already waiting to be received
+			}
+		}
+	}()
+	return member{cfg: sc, gateway: gateway, queue: q}, nil
+}
+
+// Update replaces the sinks of a Set. It is prepared by Prepare, then either
+// committed or aborted.
+type Update struct {
+	set     *Set
+	members []member // Every sink once committed
+	opened  []*Queue // Closed by Abort
+	retired []*Queue // Closed by Retire
+}
+
+// Prepare opens the sinks of cfg that are not open yet, whose configuration
+// changed or, for gateway sinks, whose gateway client changed. No event is
+// sent to them before the Update is committed, and the sinks of s keep
+// receiving events until then.
+func (s *Set) Prepare(cfg *config.Config, gateway *eventgateway.ServiceClient) (*Update, error) {
+	s.mu.RLock()
+	running := make(map[string]member, len(s.members))
+	for _, m := range s.members {
+		running[m.cfg.Name] = m
+	}
+	s.mu.RUnlock()
+
+	u := &Update{set: s}
+	for _, sc := range cfg.Sinks {
+		m, ok := running[sc.Name]
+		if ok && reflect.DeepEqual(m.cfg, sc) && (sc.Type != config.SinkGateway || m.gateway == gateway) {
+			u.members = append(u.members, m)
+			delete(running, sc.Name)
+			continue
+		}
+		if ok && sc.Type == config.SinkServer && m.cfg.Type == config.SinkServer && m.cfg.Subscriptions.Addr == sc.Subscriptions.Addr {
+			u.Abort()
+			return nil, fmt.Errorf("sink %s: subscriptions sink cannot be reconfigured while it listens on %s, change its address or restart", sc.Name, sc.Subscriptions.Addr)
+		}
+		opened, err := s.open(cfg, sc, gateway)
+		if err != nil {
+			u.Abort()
+			return nil, err
+		}
+		u.members = append(u.members, opened)
+		u.opened = append(u.opened, opened.queue)
+	}
+	for _, m := range running {
+		u.retired = append(u.retired, m.queue)
+	}
+	return u, nil
+}
+
+// Commit sends every subsequent event to the sinks of the Update. The sinks
+// it replaced still need to be closed with Retire.
+func (u *Update) Commit() {
+	u.set.mu.Lock()
+	u.set.members = u.members
+	u.set.mu.Unlock()
+}
+
+// Abort closes the sinks opened by Prepare, leaving the Set as it was.
+func (u *Update) Abort() {
+	closeQueues(context.Background(), u.opened)
+}
+
+// Retire drains and closes the sinks replaced by a committed Update.
+func (u *Update) Retire(ctx context.Context) error {
+	return closeQueues(ctx, u.retired)
+}
+
+// Check opens the sink configured by sc on its own, probes its endpoint if
+// it has one, flushes it and closes it, returning why it could not be opened,
+// its endpoint cannot be reached or it is not healthy.
+func Check(ctx context.Context, cfg *config.Config, sc config.SinkConfig, gateway *eventgateway.ServiceClient) error {
+	sink, err := open(cfg, sc, gateway)
+	if err != nil {
+		return err
+	}
+	if prober, ok := sink.(Prober); ok {
+		err = prober.Probe(ctx)
+	}
+	if err == nil {
+		err = sink.Flush(ctx)
+	}
+	if err == nil {
+		err = sink.Health()
+	}
+	if closeErr := sink.Close(ctx); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func open(cfg *config.Config, sc config.SinkConfig, gateway *eventgateway.ServiceClient) (Sink, error) {
+	switch sc.Type {
+	case config.SinkGateway:
+		return eventgateway.NewSink(gateway, cfg)
+	case config.SinkFile:
+		return file.New(sc.File)
+	case config.SinkStdout:
+		return stdout.New(sc.Stdout), nil
+	case config.SinkTraces:
+		return otlp.NewTraces(sc.OTLP)
+	case config.SinkLogs:
+		return otlp.NewLogs(sc.OTLP)
+	case config.SinkMetrics:
+		return red.New(sc.Metrics)
+	case config.SinkWebhook:
+		return webhook.New(sc.Webhook)
+	case config.SinkLoki:
+		return loki.New(sc.Loki)
+	case config.SinkSearch:
+		return opensearch.New(sc.OpenSearch)
+	case config.SinkParquet:
+		return archive.New(sc.Parquet)
+	case config.SinkKafka:
+		return kafka.New(sc.Kafka)
+	case config.SinkServer:
+		return subscription.New(sc.Subscriptions)
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", sc.Type)
+	}
+}
+
+// Send enqueues e for every sink. The sinks are not locked while e is
+// enqueued, so that a full queue blocking Send does not hold back a Commit.
+// A sink retired meanwhile misses e, which its replacement receives from the
+// next Send.
+func (s *Set) Send(ctx context.Context, e *pb.PixieEvent) error {
+	var errs []error
+	for _, q := range s.queues() {
+		if err := q.Send(ctx, e); err != nil && err != errQueueClosed {
+			errs = append(errs, fmt.Errorf("sink %s: %w", q.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush flushes every sink.
+func (s *Set) Flush(ctx context.Context) error {
+	return each(s.queues(), func(q *Queue) error { return q.Flush(ctx) })
+}
+
+// Close drains and closes every sink in parallel.
+func (s *Set) Close(ctx context.Context) error {
+	return closeQueues(ctx, s.queues())
+}
+
+// Health returns the reasons any sink is unhealthy.
+func (s *Set) Health() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	for _, m := range s.members {
+		if err := m.queue.Health(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", m.queue.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Failed receives the error of a sink configured to fail that stopped. Later
+// failures are dropped while one is waiting to be received.
+func (s *Set) Failed() <-chan error {
+	return s.failed
+}
+
+func (s *Set) queues() []*Queue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	queues := make([]*Queue, len(s.members))
+	for i, m := range s.members {
+		queues[i] = m.queue
+	}
+	return queues
+}
+
+func closeQueues(ctx context.Context, queues []*Queue) error {
+	return each(queues, func(q *Queue) error { return q.Close(ctx) })
+}
+
+func each(queues []*Queue, fn func(q *Queue) error) error {
+	errs := make([]error, len(queues))
+	var wg sync.WaitGroup
+	for i, q := range queues {
+		wg.Add(1)
+		go func(i int, q *Queue) {
+			defer wg.Done()
+			if err := fn(q); err != nil {
+				errs[i] = fmt.Errorf("sink %s: %w", q.name, err)
+			}
+		}(i, q)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
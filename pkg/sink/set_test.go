@@ -0,0 +1,113 @@
+package sink
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+// stuckSink blocks every Send until its context is done, like a sink whose
+// endpoint stopped answering.
+type stuckSink struct{}
+
+func (stuckSink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (stuckSink) Flush(ctx context.Context) error { return nil }
+func (stuckSink) Close(ctx context.Context) error { return nil }
+func (stuckSink) Health() error                   { return nil }
+
+// recordingSink records the UPIDs of the events it is sent.
+type recordingSink struct {
+	mu    sync.Mutex
+	upids []string
+}
+
+func (s *recordingSink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.upids = append(s.upids, e.Upid)
+	return nil
+}
+
+func (s *recordingSink) Flush(ctx context.Context) error { return nil }
+func (s *recordingSink) Close(ctx context.Context) error { return nil }
+func (s *recordingSink) Health() error                   { return nil }
+
+func testSinkConfig(name string) config.SinkConfig {
+	return config.SinkConfig{
+		Name:    name,
+		OnError: config.OnErrorDrop,
+		Queue: config.QueueConfig{
+			Size:          1,
+			Overflow:      config.OverflowBlock,
+			DrainDeadline: config.Duration(time.Millisecond),
+			FlushInterval: config.Duration(time.Second),
+		},
+	}
+}
+
+func TestCommitIsNotBlockedBySendToFullQueue(t *testing.T) {
+	stuck := NewQueue(stuckSink{}, testSinkConfig("stuck"))
+	set := &Set{members: []member{{cfg: testSinkConfig("stuck"), queue: stuck}}, failed: make(chan error, 1)}
+
+	// The first event is handed to the sink, the second fills the queue and
+	// the third blocks Send.
+	sent := make(chan error)
+	go func() {
+		for _, upid := range []string{"1", "2", "3"} {
+			if err := set.Send(context.Background(), &pb.PixieEvent{Upid: upid}); err != nil {
+				sent <- err
+				return
+			}
+		}
+		sent <- nil
+	}()
+	select {
+	case err := <-sent:
+		t.Fatalf("Send returned %v, want it blocked by the full queue", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	replacement := &recordingSink{}
+	update := &Update{
+		set:     set,
+		members: []member{{cfg: testSinkConfig("replacement"), queue: NewQueue(replacement, testSinkConfig("replacement"))}},
+		retired: []*Queue{stuck},
+	}
+	committed := make(chan struct{})
+	go func() {
+		update.Commit()
+		close(committed)
+	}()
+	select {
+	case <-committed:
+	case <-time.After(time.Second):
+		t.Fatal("Commit blocked by a Send to a full queue")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	update.Retire(ctx)
+	if err := <-sent; err != nil {
+		t.Fatalf("Send returned %v once its sink was retired, want nil", err)
+	}
+
+	if err := set.Send(context.Background(), &pb.PixieEvent{Upid: "4"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	replacement.mu.Lock()
+	defer replacement.mu.Unlock()
+	if len(replacement.upids) != 1 || replacement.upids[0] != "4" {
+		t.Fatalf("replacement was sent %q, want only the event sent after Commit", replacement.upids)
+	}
+}
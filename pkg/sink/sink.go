@@ -0,0 +1,29 @@
+package sink
+
+import (
+	"context"
+
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+// Sink is a destination for events.
+// Sinks must treat events as read only, since every sink receives the same event.
+type Sink interface {
+	// Send delivers e, or buffers it until the next Flush.
+	Send(ctx context.Context, e *pb.PixieEvent) error
+	// Flush delivers the buffered events.
+	Flush(ctx context.Context) error
+	// Close flushes and releases the sink.
+	Close(ctx context.Context) error
+	// Health returns nil while the sink is able to deliver events, or the
+	// reason it is not.
+	Health() error
+}
+
+// Prober is implemented by the sinks delivering events to a remote endpoint,
+// to check that the endpoint can be reached without delivering any event.
+type Prober interface {
+	// Probe returns why the endpoint cannot be reached, or why it rejects
+	// the sink's requests.
+	Probe(ctx context.Context) error
+}
@@ -0,0 +1,250 @@
+// Package stdout prints events to the terminal as an aligned table or as a
+// compact line per event.
+package stdout
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"orbservability/observer/pkg/config"
+	"orbservability/observer/pkg/event"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+// headerEvery is how many table rows are printed between repeated headers.
+const headerEvery = 40
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// column renders one field of an event. Table columns are padded to width,
+// or to the sink's maximum width if it is smaller.
+type column struct {
+	header string
+	width  int
+	value  func(e *pb.PixieEvent) string
+	color  func(e *pb.PixieEvent) string // ANSI colour of the value, if any
+}
+
+var columns = map[string]column{
+	"time":      {header: "TIME", width: 12, value: formatTime},
+	"source":    {header: "SOURCE", width: 12, value: (*pb.PixieEvent).GetSource},
+	"namespace": {header: "NAMESPACE", width: 16, value: (*pb.PixieEvent).GetKubernetesNamespace},
+	"service":   {header: "SERVICE", width: 24, value: (*pb.PixieEvent).GetKubernetesService},
+	"side":      {header: "SIDE", width: 6, value: event.Side},
+	"remote":    {header: "REMOTE", width: 24, value: formatRemote},
+	"protocol":  {header: "PROTO", width: 5, value: event.Protocol, color: func(*pb.PixieEvent) string { return ansiBlue }},
+	"latency":   {header: "LATENCY", width: 9, value: formatLatency, color: colorLatency},
+	"request":   {header: "REQUEST", width: 48, value: event.Request},
+	"status":    {header: "STATUS", width: 6, value: formatStatus, color: colorStatus},
+	"upid":      {header: "UPID", width: 36, value: (*pb.PixieEvent).GetUpid},
+}
+
+// Sink prints events to stdout. Sink is not safe for concurrent use.
+type Sink struct {
+	w       *bufio.Writer
+	table   bool
+	columns []column
+	widths  []int
+	color   bool
+	rows    int // Rows printed since the last header
+}
+
+// New creates a sink printing to stdout.
+func New(cfg config.StdoutSinkConfig) *Sink {
+	return newSink(os.Stdout, cfg, cfg.Color == config.ColorAlways || cfg.Color == config.ColorAuto && isTerminal(os.Stdout))
+}
+
+func newSink(w io.Writer, cfg config.StdoutSinkConfig, color bool) *Sink {
+	s := &Sink{
+		w:     bufio.NewWriter(w),
+		table: cfg.Format == config.FormatTable,
+		color: color,
+	}
+	for _, name := range cfg.Columns {
+		c := columns[name]
+		width := cfg.MaxWidth
+		if s.table {
+			width = min(c.width, cfg.MaxWidth)
+		}
+		s.columns = append(s.columns, c)
+		s.widths = append(s.widths, width)
+	}
+	return s
+}
+
+// isTerminal reports whether f is a terminal that has not opted out of colour.
+func isTerminal(f *os.File) bool {
+	if _, noColor := os.LookupEnv("NO_COLOR"); noColor {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (s *Sink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	if s.table && s.rows%headerEvery == 0 {
+		s.header()
+	}
+	s.rows++
+
+	written := false
+	for i, c := range s.columns {
+		value := truncate(c.value(e), s.widths[i])
+		if !s.table && value == "" {
+			continue // Compact lines skip empty values rather than align them
+		}
+		if written {
+			s.w.WriteString(" ")
+		}
+		written = true
+		if s.color && c.color != nil {
+			if code := c.color(e); code != "" {
+				s.w.WriteString(code + value + ansiReset)
+				s.pad(value, i)
+				continue
+			}
+		}
+		s.w.WriteString(value)
+		s.pad(value, i)
+	}
+	_, err := s.w.WriteString("\n")
+	return err
+}
+
+func (s *Sink) header() {
+	if s.color {
+		s.w.WriteString(ansiBold)
+	}
+	for i, c := range s.columns {
+		if i > 0 {
+			s.w.WriteString(" ")
+		}
+		header := truncate(c.header, s.widths[i])
+		s.w.WriteString(header)
+		s.pad(header, i)
+	}
+	if s.color {
+		s.w.WriteString(ansiReset)
+	}
+	s.w.WriteString("\n")
+}
+
+// pad aligns the table after value was written in column i.
+// The last column is not padded, nor are compact lines.
+func (s *Sink) pad(value string, i int) {
+	if !s.table || i == len(s.columns)-1 {
+		return
+	}
+	if n := s.widths[i] - utf8.RuneCountInString(value); n > 0 {
+		s.w.WriteString(strings.Repeat(" ", n))
+	}
+}
+
+func (s *Sink) Flush(ctx context.Context) error {
+	return s.w.Flush()
+}
+
+func (s *Sink) Close(ctx context.Context) error {
+	return s.w.Flush()
+}
+
+func (s *Sink) Health() error {
+	return nil
+}
+
+// truncate shortens value to width runes, marking the cut with an ellipsis.
+// Line breaks are replaced so that every event stays on one line.
+func truncate(value string, width int) string {
+	value = strings.NewReplacer("\r\n", " ", "\n", " ", "\t", " ").Replace(value)
+	if utf8.RuneCountInString(value) <= width {
+		return value
+	}
+	if width < 1 {
+		return ""
+	}
+	runes := []rune(value)
+	return string(runes[:width-1]) + "…"
+}
+
+func formatTime(e *pb.PixieEvent) string {
+	t, ok := event.Time(e)
+	if !ok {
+		return e.GetTime()
+	}
+	return t.Local().Format("15:04:05.000")
+}
+
+func formatRemote(e *pb.PixieEvent) string {
+	if service := e.GetKubernetesRemoteService(); service != "" {
+		return service
+	}
+	if e.GetRemoteAddr() == "" {
+		return ""
+	}
+	return e.GetRemoteAddr() + ":" + strconv.Itoa(int(e.GetRemotePort()))
+}
+
+func formatLatency(e *pb.PixieEvent) string {
+	latency := time.Duration(e.GetLatency())
+	switch {
+	case latency >= time.Second:
+		return latency.Round(time.Millisecond).String()
+	case latency >= time.Millisecond:
+		return latency.Round(10 * time.Microsecond).String()
+	default:
+		return latency.Round(time.Microsecond).String()
+	}
+}
+
+func formatStatus(e *pb.PixieEvent) string {
+	status, ok := event.Status(e)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatInt(status, 10)
+}
+
+func colorLatency(e *pb.PixieEvent) string {
+	switch latency := time.Duration(e.GetLatency()); {
+	case latency >= time.Second:
+		return ansiRed
+	case latency >= 100*time.Millisecond:
+		return ansiYellow
+	default:
+		return ansiDim
+	}
+}
+
+// colorStatus colours HTTP statuses by class. The status codes of other
+// protocols are protocol specific and left uncoloured.
+func colorStatus(e *pb.PixieEvent) string {
+	http, ok := e.GetProtocolData().(*pb.PixieEvent_Http)
+	if !ok {
+		return ""
+	}
+	switch status := http.Http.GetRespStatus(); {
+	case status >= 500:
+		return ansiRed
+	case status >= 400:
+		return ansiYellow
+	case status >= 300:
+		return ansiCyan
+	default:
+		return ansiGreen
+	}
+}
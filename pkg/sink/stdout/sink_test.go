@@ -0,0 +1,128 @@
+package stdout
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		value string
+		width int
+		want  string
+	}{
+		{value: "GET /health", width: 48, want: "GET /health"},
+		{value: "GET /health", width: 11, want: "GET /health"},
+		{value: "GET /health", width: 10, want: "GET /heal…"},
+		{value: "héllo wörld", width: 7, want: "héllo …"},
+		{value: "SELECT 1\r\nFROM\tt", width: 48, want: "SELECT 1 FROM t"},
+		{value: "line\nbreak", width: 8, want: "line br…"},
+		{value: "GET /health", width: 1, want: "…"},
+		{value: "GET /health", width: 0, want: ""},
+		{value: "", width: 0, want: ""},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.value, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.value, tt.width, got, tt.want)
+		}
+	}
+}
+
+func httpEvent(namespace string, path string, status int32) *pb.PixieEvent {
+	return &pb.PixieEvent{
+		KubernetesNamespace: namespace,
+		ProtocolData: &pb.PixieEvent_Http{Http: &pb.HypertextTransferProtocol{
+			ReqMethod:  "GET",
+			ReqPath:    path,
+			RespStatus: status,
+		}},
+	}
+}
+
+// output sends events to a sink printing without colour and returns its lines.
+func output(t *testing.T, cfg config.StdoutSinkConfig, events ...*pb.PixieEvent) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	s := newSink(&buf, cfg, false)
+	for _, e := range events {
+		if err := s.Send(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func TestSend(t *testing.T) {
+	columns := []string{"namespace", "protocol", "request", "status"}
+	events := []*pb.PixieEvent{
+		httpEvent("default", "/health", 200),
+		httpEvent("", "/", 503),
+	}
+	tests := []struct {
+		name string
+		cfg  config.StdoutSinkConfig
+		want []string
+	}{
+		{
+			name: "table",
+			cfg:  config.StdoutSinkConfig{Format: config.FormatTable, Columns: columns, MaxWidth: 48},
+			want: []string{
+				"NAMESPACE        PROTO REQUEST                                          STATUS",
+				"default          http  GET /health                                      200",
+				"                 http  GET /                                            503",
+			},
+		},
+		{
+			name: "table narrowed to max_width",
+			cfg:  config.StdoutSinkConfig{Format: config.FormatTable, Columns: columns, MaxWidth: 10},
+			want: []string{
+				"NAMESPACE  PROTO REQUEST    STATUS",
+				"default    http  GET /heal… 200",
+				"           http  GET /      503",
+			},
+		},
+		{
+			name: "compact",
+			cfg:  config.StdoutSinkConfig{Format: config.FormatCompact, Columns: columns, MaxWidth: 10},
+			want: []string{
+				"default http GET /heal… 200",
+				"http GET / 503",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := output(t, tt.cfg, events...)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Send printed\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestSendRepeatsHeader(t *testing.T) {
+	events := make([]*pb.PixieEvent, 2*headerEvery+1)
+	for i := range events {
+		events[i] = httpEvent("default", "/", 200)
+	}
+	lines := output(t, config.StdoutSinkConfig{Format: config.FormatTable, Columns: []string{"namespace", "status"}, MaxWidth: 48}, events...)
+
+	var headers []int
+	for i, line := range lines {
+		if strings.HasPrefix(line, "NAMESPACE") {
+			headers = append(headers, i)
+		}
+	}
+	want := []int{0, headerEvery + 1, 2 * (headerEvery + 1)}
+	if len(lines) != len(events)+len(want) || len(headers) != len(want) || headers[1] != want[1] || headers[2] != want[2] {
+		t.Fatalf("%d lines with headers on lines %v, want %d lines with headers on lines %v", len(lines), headers, len(events)+len(want), want)
+	}
+}
@@ -0,0 +1,217 @@
+// Package subscription serves events to local clients subscribing over gRPC,
+// re-publishing the stream the observer sends to its other sinks.
+package subscription
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+	"orbservability/observer/pkg/processor"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	subscribers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "observer_subscribers",
+		Help: "Clients subscribed to the events.",
+	}, []string{"addr"})
+
+	subscriberDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "observer_subscriber_dropped_events_total",
+		Help: "Events dropped because a subscriber's buffer was full.",
+	}, []string{"addr"})
+)
+
+// Sink runs a gRPC server implementing the SubscriptionService. Each
+// subscriber has its own buffer, so a slow subscriber misses events rather
+// than stalling the others or the observer.
+type Sink struct {
+	pb.UnimplementedSubscriptionServiceServer
+
+	cfg      config.SubscriptionsSinkConfig
+	server   *grpc.Server
+	closing  chan struct{}
+	stopOnce sync.Once
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	err         error // Why the server stopped serving, reported by Health
+}
+
+// subscriber is a client receiving the events matching its filter.
+type subscriber struct {
+	peer    string
+	filter  processor.Filter
+	events  chan *pb.PixieEvent
+	dropped atomic.Int64 // Since the last time it was logged
+}
+
+// New starts serving on the configured address before returning.
+func New(cfg config.SubscriptionsSinkConfig) (*Sink, error) {
+	listener, err := listen(cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return serve(cfg, listener), nil
+}
+
+// serve starts serving the subscriptions on listener.
+func serve(cfg config.SubscriptionsSinkConfig, listener net.Listener) *Sink {
+	s := &Sink{
+		cfg:         cfg,
+		server:      grpc.NewServer(),
+		closing:     make(chan struct{}),
+		subscribers: map[*subscriber]struct{}{},
+	}
+	pb.RegisterSubscriptionServiceServer(s.server, s)
+	subscribers.WithLabelValues(cfg.Addr).Set(0)
+
+	go func() {
+		err := s.server.Serve(listener)
+		if err != nil {
+			log.Error().Err(err).Str("addr", cfg.Addr).Msg("Error serving subscriptions")
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+		}
+	}()
+	log.Info().Str("addr", cfg.Addr).Msg("Serving subscriptions")
+	return s
+}
+
+// listen listens on a TCP address, or on a Unix socket for unix:/path,
+// replacing the socket left behind by a previous run.
+func listen(addr string) (net.Listener, error) {
+	path, unix := strings.CutPrefix(addr, "unix:")
+	if !unix {
+		return net.Listen("tcp", addr)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
+// Send hands the event to every subscriber whose filter it matches, dropping
+// it for those whose buffer is full.
+func (s *Sink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subscribers {
+		if !sub.filter.Process(e) {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			sub.dropped.Add(1)
+			subscriberDropped.WithLabelValues(s.cfg.Addr).Inc()
+		}
+	}
+	return nil
+}
+
+func (s *Sink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close ends the subscriptions and stops the server, forcibly once ctx is done.
+func (s *Sink) Close(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.closing) })
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.server.Stop()
+	}
+	return nil
+}
+
+func (s *Sink) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Subscribe streams the events matching req until the client goes away or
+// the sink is closed.
+func (s *Sink) Subscribe(req *pb.SubscribeRequest, stream pb.SubscriptionService_SubscribeServer) error {
+	sub := &subscriber{
+		peer: "unknown",
+		filter: processor.Filter{
+			Namespaces: req.GetNamespaces(),
+			Services:   req.GetServices(),
+			Protocols:  req.GetProtocols(),
+		},
+		events: make(chan *pb.PixieEvent, s.cfg.Buffer),
+	}
+	if p, ok := peer.FromContext(stream.Context()); ok && p.Addr.String() != "" {
+		sub.peer = p.Addr.String()
+	}
+	if err := s.add(sub); err != nil {
+		return err
+	}
+	defer s.remove(sub)
+	// Send the headers right away, so the client knows it is subscribed
+	// before the first event
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	logger := log.With().Str("addr", s.cfg.Addr).Str("peer", sub.peer).Logger()
+	logger.Info().Strs("namespaces", req.GetNamespaces()).Strs("services", req.GetServices()).Strs("protocols", req.GetProtocols()).Msg("Subscribed")
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			logger.Info().Msg("Unsubscribed")
+			return nil
+		case <-s.closing:
+			return status.Error(codes.Unavailable, "observer shutting down")
+		case e := <-sub.events:
+			if dropped := sub.dropped.Swap(0); dropped > 0 {
+				logger.Warn().Int64("dropped", dropped).Msg("Dropped events for slow subscriber")
+			}
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// add registers a subscriber, unless there are as many as allowed.
+func (s *Sink) add(sub *subscriber) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.subscribers) >= s.cfg.MaxSubscribers {
+		return status.Errorf(codes.ResourceExhausted, "at most %d subscribers allowed", s.cfg.MaxSubscribers)
+	}
+	s.subscribers[sub] = struct{}{}
+	subscribers.WithLabelValues(s.cfg.Addr).Set(float64(len(s.subscribers)))
+	return nil
+}
+
+func (s *Sink) remove(sub *subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscribers, sub)
+	subscribers.WithLabelValues(s.cfg.Addr).Set(float64(len(s.subscribers)))
+}
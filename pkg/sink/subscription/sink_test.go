@@ -0,0 +1,234 @@
+package subscription
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+// serveInMemory serves a sink in memory and returns a client connected to it.
+func serveInMemory(t *testing.T, cfg config.SubscriptionsSinkConfig) (*Sink, pb.SubscriptionServiceClient) {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	s := serve(cfg, listener)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		s.Close(ctx)
+	})
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, pb.NewSubscriptionServiceClient(conn)
+}
+
+// subscribe subscribes until ctx is done, returning once the sink registered
+// the subscriber.
+func subscribe(ctx context.Context, t *testing.T, client pb.SubscriptionServiceClient, req *pb.SubscribeRequest) pb.SubscriptionService_SubscribeClient {
+	t.Helper()
+	stream, err := client.Subscribe(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Header(); err != nil {
+		t.Fatalf("subscribing returned %v", err)
+	}
+	return stream
+}
+
+func testConfig(addr string) config.SubscriptionsSinkConfig {
+	return config.SubscriptionsSinkConfig{Addr: addr, Buffer: 100, MaxSubscribers: 10}
+}
+
+func testEvent(upid string, namespace string, service string, protocol string) *pb.PixieEvent {
+	e := &pb.PixieEvent{Upid: upid, KubernetesNamespace: namespace, KubernetesService: service}
+	switch protocol {
+	case "http":
+		e.ProtocolData = &pb.PixieEvent_Http{Http: &pb.HypertextTransferProtocol{}}
+	case "pgsql":
+		e.ProtocolData = &pb.PixieEvent_Pgsql{Pgsql: &pb.PostgreSQL{}}
+	}
+	return e
+}
+
+func TestFilters(t *testing.T) {
+	s, client := serveInMemory(t, testConfig("bufnet-filters"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The last event matches every filter, and tells the subscriber that
+	// it received all the events it was sent
+	events := []*pb.PixieEvent{
+		testEvent("cart", "shop", "shop/cart", "http"),
+		testEvent("db", "shop", "shop/db", "pgsql"),
+		testEvent("api", "default", "default/api", "http"),
+		testEvent("dns", "default", "", ""),
+		testEvent("last", "shop", "shop/cart", "http"),
+	}
+	tests := []struct {
+		name  string
+		req   *pb.SubscribeRequest
+		upids []string
+	}{
+		{name: "everything", req: &pb.SubscribeRequest{}, upids: []string{"cart", "db", "api", "dns", "last"}},
+		{name: "namespace", req: &pb.SubscribeRequest{Namespaces: []string{"shop"}}, upids: []string{"cart", "db", "last"}},
+		{name: "services", req: &pb.SubscribeRequest{Services: []string{"shop/cart", "default/api"}}, upids: []string{"cart", "api", "last"}},
+		{name: "protocol", req: &pb.SubscribeRequest{Protocols: []string{"http"}}, upids: []string{"cart", "api", "last"}},
+		{name: "namespace and protocol", req: &pb.SubscribeRequest{Namespaces: []string{"shop"}, Protocols: []string{"http"}}, upids: []string{"cart", "last"}},
+	}
+	streams := make([]pb.SubscriptionService_SubscribeClient, len(tests))
+	for i, tt := range tests {
+		streams[i] = subscribe(ctx, t, client, tt.req)
+	}
+	for _, e := range events {
+		if err := s.Send(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var upids []string
+			for !slices.Contains(upids, "last") {
+				e, err := streams[i].Recv()
+				if err != nil {
+					t.Fatalf("Recv returned %v after %q", err, upids)
+				}
+				upids = append(upids, e.GetUpid())
+			}
+			if !slices.Equal(upids, tt.upids) {
+				t.Errorf("received %q, want %q", upids, tt.upids)
+			}
+		})
+	}
+}
+
+func TestSlowSubscriberDoesNotStallSend(t *testing.T) {
+	cfg := testConfig("bufnet-slow")
+	cfg.Buffer = 1
+	s, client := serveInMemory(t, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := subscribe(ctx, t, client, &pb.SubscribeRequest{}) // Received from once the events are sent
+
+	// Far more than the flow control windows of the connection let through
+	const events = 512
+	body := strings.Repeat("x", 64<<10)
+	sent := make(chan error)
+	go func() {
+		for i := 0; i < events; i++ {
+			e := &pb.PixieEvent{ProtocolData: &pb.PixieEvent_Http{Http: &pb.HypertextTransferProtocol{RespBody: body}}}
+			if err := s.Send(ctx, e); err != nil {
+				sent <- err
+				return
+			}
+		}
+		sent <- nil
+	}()
+	select {
+	case err := <-sent:
+		if err != nil {
+			t.Fatalf("Send returned %v, want nil", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Send stalled by a subscriber that does not receive")
+	}
+
+	received := make(chan struct{}, events)
+	go func() {
+		for {
+			if _, err := stream.Recv(); err != nil {
+				return
+			}
+			received <- struct{}{}
+		}
+	}()
+	n := 0
+	for idle := false; !idle; {
+		select {
+		case <-received:
+			n++
+		case <-time.After(200 * time.Millisecond):
+			idle = true
+		}
+	}
+	if n == 0 || n >= events {
+		t.Fatalf("slow subscriber received %d of %d events, want the events sent while its buffer was full dropped", n, events)
+	}
+}
+
+func TestMaxSubscribers(t *testing.T) {
+	cfg := testConfig("bufnet-max")
+	cfg.MaxSubscribers = 1
+	_, client := serveInMemory(t, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	firstCtx, unsubscribe := context.WithCancel(ctx)
+	subscribe(firstCtx, t, client, &pb.SubscribeRequest{})
+
+	refused, err := client.Subscribe(ctx, &pb.SubscribeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := refused.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Recv returned %v for a subscriber beyond the maximum, want ResourceExhausted", err)
+	}
+
+	// The first subscriber leaving makes room for another
+	unsubscribe()
+	deadline := time.Now().Add(time.Second)
+	for {
+		stream, err := client.Subscribe(ctx, &pb.SubscribeRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if md, err := stream.Header(); err == nil && md != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscribers still refused after the first one left")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+	s, client := serveInMemory(t, testConfig("bufnet-close"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := subscribe(ctx, t, client, &pb.SubscribeRequest{})
+
+	closed := make(chan error)
+	go func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		closed <- s.Close(closeCtx)
+	}()
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Fatalf("Recv returned %v once the sink closed, want Unavailable", err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	if err := s.Health(); err != nil {
+		t.Errorf("Health returned %v after a graceful stop, want nil", err)
+	}
+}
@@ -0,0 +1,235 @@
+// Package webhook posts batches of events as JSON to an HTTP endpoint.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+	"orbservability/observer/pkg/sink/retry"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var errBreakerOpen = errors.New("circuit breaker open, batch dropped")
+
+// Sink posts events in batches of up to the configured size, or sooner on
+// flush. Sink is not safe for concurrent use.
+type Sink struct {
+	cfg     config.WebhookSinkConfig
+	headers map[string]string
+	client  *http.Client
+	batch   []*pb.PixieEvent
+	breaker breaker
+
+	mu  sync.Mutex
+	err error // Last request error, reported by Health
+}
+
+func New(cfg config.WebhookSinkConfig) (*Sink, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.TLS.Enabled {
+		tlsConfig, err := cfg.TLS.HTTPClientConfig(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	s := &Sink{
+		cfg:     cfg,
+		headers: map[string]string{},
+		client:  &http.Client{Transport: transport},
+		breaker: breaker{failures: cfg.Breaker.Failures, cooldown: cfg.Breaker.Cooldown.Duration()},
+	}
+	for name, value := range cfg.Headers {
+		s.headers[name] = value.Value()
+	}
+	return s, nil
+}
+
+func (s *Sink) Send(ctx context.Context, e *pb.PixieEvent) error {
+	s.batch = append(s.batch, e)
+	if len(s.batch) < s.cfg.BatchSize {
+		return nil
+	}
+	return s.Flush(ctx)
+}
+
+// Flush posts the batch. A batch that still fails after its retries, or is
+// flushed while the breaker is open, is dropped.
+func (s *Sink) Flush(ctx context.Context) error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	body, err := s.encode(s.batch)
+	s.batch = s.batch[:0]
+	if err != nil {
+		return err
+	}
+
+	if !s.breaker.allow(time.Now()) {
+		return errBreakerOpen
+	}
+	err = retry.Do(ctx, s.cfg.Retry, func(ctx context.Context) error {
+		return s.post(ctx, body)
+	}, retry.HTTP)
+	if s.breaker.record(err == nil, time.Now()) {
+		log.Warn().Err(err).Str("url", s.cfg.URL).Dur("cooldown", s.breaker.cooldown).Msg("Webhook circuit breaker opened")
+	}
+
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+	return err
+}
+
+func (s *Sink) Close(ctx context.Context) error {
+	err := s.Flush(ctx)
+	s.client.CloseIdleConnections()
+	return err
+}
+
+func (s *Sink) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// encode renders the events as a JSON array, or as a protojson line each for NDJSON.
+func (s *Sink) encode(events []*pb.PixieEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	if s.cfg.Format == config.FormatJSON {
+		buf.WriteByte('[')
+	}
+	for i, e := range events {
+		line, err := protojson.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && s.cfg.Format == config.FormatJSON {
+			buf.WriteByte(',')
+		}
+		buf.Write(line)
+		if s.cfg.Format == config.FormatNDJSON {
+			buf.WriteByte('\n')
+		}
+	}
+	if s.cfg.Format == config.FormatJSON {
+		buf.WriteByte(']')
+	}
+	return buf.Bytes(), nil
+}
+
+// post sends one request within the configured timeout.
+func (s *Sink) post(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if s.cfg.Format == config.FormatNDJSON {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+	if s.cfg.Secret != "" {
+		req.Header.Set(s.cfg.SignatureHeader, sign(s.cfg.Secret.Value(), body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := retry.CheckResponse(resp); err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20)) // Lets the connection be reused
+	return nil
+}
+
+// Probe sends a HEAD request to the receiver with the configured headers. A
+// receiver that only accepts POST may answer with any status, so only a
+// request that fails or is answered with 401 or 403 fails the probe.
+func (s *Sink) Probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout.Duration())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.cfg.URL, nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return &retry.StatusError{Code: resp.StatusCode}
+	}
+	return nil
+}
+
+// sign returns the hex encoded HMAC-SHA256 of body, prefixed by the algorithm
+// like GitHub's webhook signatures.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// breaker opens after a number of consecutive failures, and lets a single
+// attempt through once it has been open for the cooldown.
+type breaker struct {
+	failures int // Consecutive failures that open the breaker, 0 or less disables
+	cooldown time.Duration
+
+	failed   int       // Consecutive failures so far
+	openedAt time.Time // Zero while closed
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	return b.openedAt.IsZero() || now.Sub(b.openedAt) >= b.cooldown
+}
+
+// record counts the outcome of an attempt, returning whether it opened the breaker.
+func (b *breaker) record(ok bool, now time.Time) bool {
+	if ok {
+		b.failed = 0
+		b.openedAt = time.Time{}
+		return false
+	}
+	b.failed++
+	if b.failures <= 0 {
+		return false
+	}
+	if !b.openedAt.IsZero() {
+		b.openedAt = now // Reopened after a failed trial
+		return false
+	}
+	if b.failed >= b.failures {
+		b.openedAt = now
+		return true
+	}
+	return false
+}
@@ -0,0 +1,304 @@
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"orbservability/observer/pkg/config"
+	pb "orbservability/observer/pkg/gen/pb/v1"
+)
+
+// receiver is a webhook endpoint answering each request with the next of
+// responses, and 200 once they run out.
+type receiver struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses []response
+	requests  []request
+}
+
+type response struct {
+	status     int
+	retryAfter string
+}
+
+type request struct {
+	at     time.Time
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, responses ...response) *receiver {
+	r := &receiver{responses: responses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, request{at: time.Now(), header: req.Header, body: body})
+		if len(r.responses) == 0 {
+			return
+		}
+		resp := r.responses[0]
+		r.responses = r.responses[1:]
+		if resp.retryAfter != "" {
+			w.Header().Set("Retry-After", resp.retryAfter)
+		}
+		w.WriteHeader(resp.status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() []request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]request(nil), r.requests...)
+}
+
+func (r *receiver) respond(responses ...response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses = responses
+}
+
+func testConfig(url string) config.WebhookSinkConfig {
+	return config.WebhookSinkConfig{
+		URL:             url,
+		Format:          config.FormatJSON,
+		SignatureHeader: "X-Observer-Signature",
+		Timeout:         config.Duration(5 * time.Second),
+		BatchSize:       100,
+		Retry: config.RetryConfig{
+			MaxAttempts: 3,
+			Backoff:     config.BackoffConfig{Base: config.Duration(time.Millisecond), Max: config.Duration(time.Millisecond)},
+		},
+		Breaker: config.BreakerConfig{Failures: -1},
+	}
+}
+
+func sendAll(t *testing.T, s *Sink, events ...*pb.PixieEvent) error {
+	t.Helper()
+	for _, e := range events {
+		if err := s.Send(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s.Flush(context.Background())
+}
+
+func TestRetriesHonourRetryAfter(t *testing.T) {
+	r := newReceiver(t, response{status: http.StatusTooManyRequests, retryAfter: "1"}, response{status: http.StatusServiceUnavailable})
+	s, err := New(testConfig(r.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sendAll(t, s, &pb.PixieEvent{Upid: "1"}); err != nil {
+		t.Fatalf("Flush returned %v, want the batch delivered on the third attempt", err)
+	}
+	requests := r.received()
+	if len(requests) != 3 {
+		t.Fatalf("%d requests, want 3", len(requests))
+	}
+	if waited := requests[1].at.Sub(requests[0].at); waited < time.Second {
+		t.Errorf("retried after %s, want the second asked for by Retry-After", waited)
+	}
+	if waited := requests[2].at.Sub(requests[1].at); waited >= time.Second {
+		t.Errorf("retried after %s, want the backoff delay without Retry-After", waited)
+	}
+	if s.Health() != nil {
+		t.Errorf("Health returned %v after the batch was delivered", s.Health())
+	}
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	r := newReceiver(t, response{status: http.StatusBadRequest})
+	s, err := New(testConfig(r.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sendAll(t, s, &pb.PixieEvent{Upid: "1"}); err == nil {
+		t.Fatal("Flush returned nil, want the 400")
+	}
+	if n := len(r.received()); n != 1 {
+		t.Fatalf("%d requests, want 1", n)
+	}
+}
+
+func TestSignsBody(t *testing.T) {
+	r := newReceiver(t)
+	cfg := testConfig(r.URL)
+	cfg.Secret = "shared secret"
+	cfg.Headers = map[string]config.Secret{"Authorization": "Bearer token"}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sendAll(t, s, &pb.PixieEvent{Upid: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	req := r.received()[0]
+	mac := hmac.New(sha256.New, []byte("shared secret"))
+	mac.Write(req.body)
+	if got, want := req.header.Get("X-Observer-Signature"), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if got := req.header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q, want the configured header", got)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	events := []*pb.PixieEvent{{Upid: "1"}, {Upid: "2", KubernetesNamespace: "shop"}}
+
+	t.Run("json", func(t *testing.T) {
+		r := newReceiver(t)
+		s, err := New(testConfig(r.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sendAll(t, s, events...); err != nil {
+			t.Fatal(err)
+		}
+		req := r.received()[0]
+		if got := req.header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", got)
+		}
+		var decoded []map[string]any
+		if err := json.Unmarshal(req.body, &decoded); err != nil {
+			t.Fatalf("body %s is not a JSON array: %v", req.body, err)
+		}
+		if len(decoded) != 2 || decoded[0]["upid"] != "1" || decoded[1]["kubernetesNamespace"] != "shop" {
+			t.Errorf("body = %s, want both events", req.body)
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		r := newReceiver(t)
+		cfg := testConfig(r.URL)
+		cfg.Format = config.FormatNDJSON
+		s, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sendAll(t, s, events...); err != nil {
+			t.Fatal(err)
+		}
+		req := r.received()[0]
+		if got := req.header.Get("Content-Type"); got != "application/x-ndjson" {
+			t.Errorf("Content-Type = %q, want application/x-ndjson", got)
+		}
+		var upids []any
+		scanner := bufio.NewScanner(bytes.NewReader(req.body))
+		for scanner.Scan() {
+			var decoded map[string]any
+			if err := json.Unmarshal(scanner.Bytes(), &decoded); err != nil {
+				t.Fatalf("line %q is not a JSON object: %v", scanner.Text(), err)
+			}
+			upids = append(upids, decoded["upid"])
+		}
+		if len(upids) != 2 || upids[0] != "1" || upids[1] != "2" {
+			t.Errorf("body = %q, want an event per line", req.body)
+		}
+	})
+}
+
+func TestBreakerOpensAndCloses(t *testing.T) {
+	r := newReceiver(t)
+	cfg := testConfig(r.URL)
+	cfg.Retry.MaxAttempts = 1
+	cfg.Breaker = config.BreakerConfig{Failures: 2, Cooldown: config.Duration(50 * time.Millisecond)}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail := response{status: http.StatusInternalServerError}
+
+	r.respond(fail, fail)
+	for i := 0; i < 2; i++ {
+		if err := sendAll(t, s, &pb.PixieEvent{}); err == nil || errors.Is(err, errBreakerOpen) {
+			t.Fatalf("Flush %d returned %v, want the 500", i+1, err)
+		}
+	}
+	if err := sendAll(t, s, &pb.PixieEvent{}); !errors.Is(err, errBreakerOpen) {
+		t.Fatalf("Flush returned %v once open, want errBreakerOpen", err)
+	}
+	if n := len(r.received()); n != 2 {
+		t.Fatalf("%d requests, want none while the breaker is open", n-2)
+	}
+
+	// A failed trial after the cooldown opens it again right away
+	time.Sleep(50 * time.Millisecond)
+	r.respond(fail)
+	if err := sendAll(t, s, &pb.PixieEvent{}); err == nil || errors.Is(err, errBreakerOpen) {
+		t.Fatalf("trial Flush returned %v, want the 500", err)
+	}
+	if err := sendAll(t, s, &pb.PixieEvent{}); !errors.Is(err, errBreakerOpen) {
+		t.Fatalf("Flush returned %v after a failed trial, want errBreakerOpen", err)
+	}
+
+	// A successful trial closes it
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if err := sendAll(t, s, &pb.PixieEvent{}); err != nil {
+			t.Fatalf("Flush %d returned %v after a successful trial, want nil", i+1, err)
+		}
+	}
+	if n := len(r.received()); n != 6 {
+		t.Fatalf("%d requests, want 6", n)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	for _, failures := range []int{0, -1} {
+		b := breaker{failures: failures, cooldown: time.Minute}
+		now := time.Now()
+		for i := 0; i < 10; i++ {
+			if b.record(false, now) || !b.allow(now) {
+				t.Fatalf("breaker with failures %d opened", failures)
+			}
+		}
+	}
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		status int
+		fails  bool
+	}{
+		{status: http.StatusOK},
+		{status: http.StatusMethodNotAllowed},
+		{status: http.StatusUnauthorized, fails: true},
+		{status: http.StatusForbidden, fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			r := newReceiver(t, response{status: tt.status})
+			s, err := New(testConfig(r.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Probe(context.Background()); (err != nil) != tt.fails {
+				t.Fatalf("Probe returned %v, want failed = %t", err, tt.fails)
+			}
+			if requests := r.received(); len(requests) != 1 || len(requests[0].body) != 0 {
+				t.Fatalf("received %d requests, want a single one without a body", len(requests))
+			}
+		})
+	}
+}